@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gdrsapi/internal/apikeys"
+	"gdrsapi/internal/usage"
+	"gdrsapi/pkg/clientip"
+)
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(req *http.Request) string {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// keyClient is how calls made with an api key are attributed in the usage
+// metrics.
+func keyClient(id string) string {
+	return "key:" + id
+}
+
+// withApiKey authenticates requests sent with an api key. Their rate limit
+// and daily quota are applied with the route's cost by rateLimited. Requests
+// without a key go through as anonymous ones, limited by client ip.
+func (app *App) withApiKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token := bearerToken(req)
+		if token == "" {
+			next(w, req)
+			return
+		}
+
+		key, err := app.apiKeySvc.Authenticate(token)
+		if err != nil {
+			apiResp := &ApiResponse{ErrorMessage: err.Error()}
+			status := http.StatusInternalServerError
+			if errors.Is(err, apikeys.ErrInvalidKey) || errors.Is(err, apikeys.ErrKeyRevoked) {
+				status = http.StatusUnauthorized
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gdrsapi"`)
+			} else {
+				app.logger.ErrorContext(req.Context(), "authenticating api key", "err", err)
+				apiResp.ErrorMessage = "Error checking the api key"
+			}
+
+			err := app.encodeJsonResponse(w, apiResp, status)
+			if err != nil {
+				app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(req.Context(), apiKeyCtxKey{}, key)
+		ctx = usage.WithAttribution(ctx, usage.Attribution{Client: keyClient(key.ID)})
+		next(w, req.WithContext(ctx))
+	}
+}
+
+// allowApiKey spends cost tokens of the key's rate limit and daily quota
+// for the route. It answers 429 and returns false when either is used up.
+func (app *App) allowApiKey(w http.ResponseWriter, req *http.Request, key *apikeys.Key, cost int) bool {
+	err := app.apiKeySvc.Allow(key, req.Pattern, cost)
+	if err == nil {
+		return true
+	}
+
+	apiResp := &ApiResponse{ErrorMessage: err.Error()}
+	status := http.StatusTooManyRequests
+	switch {
+	case errors.Is(err, apikeys.ErrRateLimited):
+		// the time the limit takes to refill the cost of the request
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(60*float64(cost)/float64(key.RateLimit)))))
+	case errors.Is(err, apikeys.ErrQuotaExceeded):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(app.usageSvc.ResetIn().Seconds()))))
+	default:
+		app.logger.ErrorContext(req.Context(), "checking api key limits", "keyId", key.ID, "err", err)
+		status = http.StatusInternalServerError
+		apiResp.ErrorMessage = "Error checking the api key"
+	}
+
+	err = app.encodeJsonResponse(w, apiResp, status)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+	return false
+}
+
+type apiKeyCtxKey struct{}
+
+// requestKey returns the api key withApiKey authenticated the request with.
+func requestKey(req *http.Request) (*apikeys.Key, bool) {
+	key, ok := req.Context().Value(apiKeyCtxKey{}).(*apikeys.Key)
+	return key, ok
+}
+
+// requestClient identifies who sent a request, the api key or else the ip.
+func requestClient(req *http.Request) string {
+	if key, ok := requestKey(req); ok {
+		return keyClient(key.ID)
+	}
+	return clientip.FromRequest(req)
+}
+
+// adminOnly lets through requests sent with ADMIN_TOKEN as their bearer
+// token. Without an admin token the admin endpoints do not exist.
+func (app *App) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if app.cfg.AdminToken == "" {
+			http.NotFound(w, req)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(bearerToken(req)), []byte(app.cfg.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gdrsapi admin"`)
+			err := app.encodeJsonResponse(w, &ApiResponse{ErrorMessage: "Admin token is missing or invalid"}, http.StatusUnauthorized)
+			if err != nil {
+				app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+			}
+			return
+		}
+		next(w, req)
+	}
+}
+
+func (app *App) apiKeysHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		app.listApiKeys(w, req)
+	case http.MethodPost:
+		app.issueApiKey(w, req)
+	default:
+		apiResp := &ApiResponse{ErrorMessage: "Only GET and POST methods are allowed"}
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+	}
+}
+
+func (app *App) apiKeyHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		app.getApiKey(w, req)
+	case http.MethodDelete:
+		app.revokeApiKey(w, req)
+	default:
+		apiResp := &ApiResponse{ErrorMessage: "Only GET and DELETE methods are allowed"}
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+	}
+}
+
+func (app *App) listApiKeys(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	keys, err := app.apiKeySvc.List()
+	if err != nil {
+		app.writeApiKeyError(w, req, err)
+		return
+	}
+
+	apiResp.Result = keys
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+// issuedApiKey is the only response that holds the key's token.
+type issuedApiKey struct {
+	*apikeys.Key
+	Token string `json:"token"`
+}
+
+func (app *App) issueApiKey(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// now check if we can process the request body
+	if err := req.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		apiResp.ErrorMessage = "Form body is too large"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	rateLimit := app.cfg.ApiKeyRateLimit
+	if v := req.PostFormValue("rateLimit"); v != "" {
+		var err error
+		rateLimit, err = strconv.Atoi(v)
+		if err != nil {
+			rateLimit = 0
+		}
+	}
+
+	// quotas are sent as repeated "route=tokens" values, such as
+	// quota=/getsteamrating=100 or quota=*=500
+	quotas := make(map[string]int)
+	for _, v := range req.PostForm["quota"] {
+		i := strings.LastIndex(v, "=")
+		n, err := strconv.Atoi(v[i+1:])
+		if i < 1 || err != nil {
+			apiResp.ErrorMessage = "quota must look like /route=requests"
+			err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+			if err != nil {
+				app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+			}
+			return
+		}
+		quotas[v[:i]] = n
+	}
+
+	key, token, err := app.apiKeySvc.Issue(req.PostFormValue("name"), rateLimit, quotas)
+	if err != nil {
+		apiResp.ErrorMessage = err.Error()
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	app.logger.InfoContext(req.Context(), "issued api key", "keyId", key.ID, "name", key.Name)
+	apiResp.Result = issuedApiKey{Key: key, Token: token}
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusCreated)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+// apiKeyDetails is a key with its request counts and spend for a day.
+type apiKeyDetails struct {
+	*apikeys.Key
+	Usage *apikeys.Usage `json:"usage"`
+	Spend usage.Totals   `json:"spend"`
+}
+
+func (app *App) getApiKey(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	key, err := app.apiKeySvc.Get(req.PathValue("id"))
+	if err != nil {
+		app.writeApiKeyError(w, req, err)
+		return
+	}
+
+	date := req.URL.Query().Get("date")
+	if date == "" {
+		date = app.apiKeySvc.Today()
+	}
+	requests, err := app.apiKeySvc.Usage(key.ID, date)
+	if err != nil {
+		apiResp.ErrorMessage = err.Error()
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+	day, err := app.usageSvc.Day(date)
+	if err != nil {
+		app.writeApiKeyError(w, req, err)
+		return
+	}
+
+	apiResp.Result = apiKeyDetails{Key: key, Usage: requests, Spend: day.ByClient[keyClient(key.ID)]}
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) revokeApiKey(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	key, err := app.apiKeySvc.Revoke(req.PathValue("id"))
+	if err != nil {
+		app.writeApiKeyError(w, req, err)
+		return
+	}
+
+	app.logger.InfoContext(req.Context(), "revoked api key", "keyId", key.ID)
+	apiResp.Result = key
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+// writeApiKeyError answers 404 for unknown keys.
+func (app *App) writeApiKeyError(w http.ResponseWriter, req *http.Request, err error) {
+	apiResp := &ApiResponse{ErrorMessage: err.Error()}
+	status := http.StatusNotFound
+	if !errors.Is(err, apikeys.ErrKeyNotFound) {
+		app.logger.ErrorContext(req.Context(), "loading api key", "err", err)
+		apiResp.ErrorMessage = "Error loading the api key"
+		status = http.StatusInternalServerError
+	}
+
+	err = app.encodeJsonResponse(w, apiResp, status)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
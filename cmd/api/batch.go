@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gdrsapi/internal/jobs"
+	"gdrsapi/internal/steamrating"
+)
+
+const (
+	batchJobKind     = "steamrating.batch"
+	batchWaitTimeout = 10 * time.Minute
+	maxBatchBodySize = 1 << 20
+)
+
+func (app *App) createSteamRatingBatch(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is POST
+	if req.Method != http.MethodPost {
+		apiResp.ErrorMessage = "Only POST method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxBatchBodySize)
+	inputs, err := parseBatchInput(req)
+	if err != nil {
+		apiResp.ErrorMessage = err.Error()
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	if len(inputs) == 0 || len(inputs) > app.cfg.BatchMaxItems {
+		apiResp.ErrorMessage = fmt.Sprintf("A batch needs between 1 and %d steam urls or app ids", app.cfg.BatchMaxItems)
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	items := steamrating.NewBatchItems(inputs)
+	ratings := 0
+	for _, item := range items {
+		if item.Status != steamrating.BatchError {
+			ratings++
+		}
+	}
+	if !app.takeRateLimit(w, req, ratingPolicy, ratings*steamrating.CallsPerRating) {
+		return
+	}
+
+	job := app.jobs.Create(batchJobKind, len(items))
+	app.jobs.Update(job.ID, func(j *jobs.Job) {
+		j.Result = slices.Clone(items)
+		for _, item := range items {
+			if item.Status == steamrating.BatchError {
+				j.Completed++
+				j.Failed++
+			}
+		}
+	})
+
+	// the batch outlives the request, so it keeps the request's usage
+	// attribution but not its cancellation
+	go app.runSteamRatingBatch(context.WithoutCancel(req.Context()), job.ID, items)
+
+	if req.URL.Query().Get("wait") == "true" {
+		job, _ = app.jobs.Wait(job.ID, batchWaitTimeout)
+		app.writeBatchResults(w, req, job)
+		return
+	}
+
+	job, _ = app.jobs.Get(job.ID)
+	apiResp.Result = job
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusAccepted)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) runSteamRatingBatch(ctx context.Context, jobId string, items []steamrating.BatchItemResult) {
+	// results is only touched under the job manager lock
+	results := slices.Clone(items)
+
+	app.batchSvc.Run(ctx, items, func(i int, item steamrating.BatchItemResult) {
+		app.jobs.Update(jobId, func(j *jobs.Job) {
+			results[i] = item
+			j.Result = slices.Clone(results)
+			j.Completed++
+			if item.Status == steamrating.BatchError {
+				j.Failed++
+			}
+		})
+	})
+
+	app.jobs.Finish(jobId, nil, nil)
+	app.logger.InfoContext(ctx, "finished steam rating batch", "jobId", jobId)
+}
+
+func (app *App) getSteamRatingBatch(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	job, ok := app.jobs.Get(req.PathValue("id"))
+	if !ok || job.Kind != batchJobKind {
+		apiResp.ErrorMessage = "Batch not found"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusNotFound)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	app.writeBatchResults(w, req, job)
+}
+
+func (app *App) getJob(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	job, ok := app.jobs.Get(req.PathValue("id"))
+	if !ok {
+		apiResp.ErrorMessage = "Job not found"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusNotFound)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	// progress only, results are served by the job's own endpoint
+	job.Result = nil
+	apiResp.Result = job
+	apiResp.Sucess = true
+	err := app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+// writeBatchResults writes the batch in the format picked by the format query
+// parameter or the Accept header. JSON includes the job progress.
+func (app *App) writeBatchResults(w http.ResponseWriter, req *http.Request, job jobs.Job) {
+	results, _ := job.Result.([]steamrating.BatchItemResult)
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		switch accept := req.Header.Get("Accept"); {
+		case strings.Contains(accept, "text/csv"):
+			format = "csv"
+		case strings.Contains(accept, "ndjson"):
+			format = "ndjson"
+		}
+	}
+
+	var err error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"steamratings-%s.csv\"", job.ID))
+		err = writeBatchCSV(w, results)
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, r := range results {
+			if err = enc.Encode(r); err != nil {
+				break
+			}
+		}
+	default:
+		err = app.encodeJsonResponse(w, &ApiResponse{Sucess: true, Result: job}, http.StatusOK)
+	}
+
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing batch results", "format", format, "err", err)
+	}
+}
+
+func writeBatchCSV(w io.Writer, results []steamrating.BatchItemResult) error {
+	cw := csv.NewWriter(w)
+
+	components := steamrating.ComponentNames()
+	header := append([]string{"input", "appId", "url", "status", "finalWeightedScore"}, components...)
+	header = append(header, "error")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		scores := make(map[string]string)
+		finalScore := ""
+		if r.Result != nil {
+			finalScore = strconv.Itoa(r.Result.FinalWeightedScore)
+			for _, c := range r.Result.ComponentRatings {
+				scores[c.Component] = strconv.Itoa(c.Score)
+			}
+		}
+
+		record := []string{r.Input, r.AppId, r.Url, r.Status, finalScore}
+		for _, c := range components {
+			record = append(record, scores[c])
+		}
+		record = append(record, r.Error)
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// parseBatchInput reads the steam urls or app ids of a batch from a JSON,
+// CSV, NDJSON or form body.
+func parseBatchInput(req *http.Request) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/json":
+		var body struct {
+			Apps []string `json:"apps"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("invalid json body: %w", err)
+		}
+		return trimInputs(body.Apps), nil
+
+	case "text/csv":
+		return parseBatchCSV(req.Body)
+
+	case "application/x-ndjson", "application/ndjson":
+		return parseBatchNDJSON(req.Body)
+
+	case "multipart/form-data", "application/x-www-form-urlencoded":
+		if err := req.ParseMultipartForm(maxBatchBodySize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return nil, fmt.Errorf("invalid form body: %w", err)
+		}
+		apps := strings.FieldsFunc(req.PostFormValue("apps"), func(r rune) bool {
+			return r == ',' || r == '\n' || r == '\r'
+		})
+		return trimInputs(apps), nil
+	}
+
+	return nil, fmt.Errorf("unsupported content type %q, use json, csv, ndjson or a form", mediaType)
+}
+
+// parseBatchCSV uses the url or appId column when there is a header row and
+// the first column otherwise.
+func parseBatchCSV(r io.Reader) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv body: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	column := 0
+	for i, cell := range records[0] {
+		name := strings.ToLower(strings.TrimSpace(cell))
+		if name == "url" || name == "appid" || name == "app_id" || name == "input" {
+			column = i
+			records = records[1:]
+			break
+		}
+	}
+
+	var inputs []string
+	for _, record := range records {
+		if column < len(record) {
+			inputs = append(inputs, record[column])
+		}
+	}
+	return trimInputs(inputs), nil
+}
+
+// parseBatchNDJSON accepts lines holding either a json string or an object
+// with a url or appId field.
+func parseBatchNDJSON(r io.Reader) ([]string, error) {
+	dec := json.NewDecoder(r)
+
+	var inputs []string
+	for line := 1; ; line++ {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ndjson on line %d: %w", line, err)
+		}
+
+		var input string
+		if err := json.Unmarshal(raw, &input); err == nil {
+			inputs = append(inputs, input)
+			continue
+		}
+
+		var obj struct {
+			Url   string `json:"url"`
+			AppId string `json:"appId"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, fmt.Errorf("invalid ndjson on line %d: expected a string or an object with url or appId", line)
+		}
+		if obj.Url != "" {
+			inputs = append(inputs, obj.Url)
+		} else {
+			inputs = append(inputs, obj.AppId)
+		}
+	}
+	return trimInputs(inputs), nil
+}
+
+func trimInputs(inputs []string) []string {
+	trimmed := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		if input = strings.TrimSpace(input); input != "" {
+			trimmed = append(trimmed, input)
+		}
+	}
+	return trimmed
+}
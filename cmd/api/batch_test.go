@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"gdrsapi/internal/steamrating"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    []string
+		fails       bool
+	}{
+		{"json", "application/json", `{"apps": ["620", " https://store.steampowered.com/app/400/ ", ""]}`, []string{"620", "https://store.steampowered.com/app/400/"}, false},
+		{"json charset", "application/json; charset=utf-8", `{"apps": ["620"]}`, []string{"620"}, false},
+		{"invalid json", "application/json", `{"apps": [620]}`, nil, true},
+		{"csv", "text/csv", "url\n620\n400\n", []string{"620", "400"}, false},
+		{"ndjson", "application/x-ndjson", "\"620\"\n{\"appId\": \"400\"}\n", []string{"620", "400"}, false},
+		{"form", "application/x-www-form-urlencoded", "apps=620%2C400%0A70", []string{"620", "400", "70"}, false},
+		{"unsupported", "text/plain", "620", nil, true},
+		{"no content type", "", "620", nil, true},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/steamratings/batch", strings.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+
+		inputs, err := parseBatchInput(req)
+		if c.fails {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", c.name, inputs)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(inputs, c.expected) {
+			t.Errorf("%s: expected %v, got %v, %v", c.name, c.expected, inputs, err)
+		}
+	}
+}
+
+func TestParseBatchCSV(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{"no header", "620,Portal 2\n400,Portal\n", []string{"620", "400"}},
+		{"url header", "name,url\nPortal 2,https://store.steampowered.com/app/620/\nPortal,400\n", []string{"https://store.steampowered.com/app/620/", "400"}},
+		{"app id header", " AppId \n620\n\n 400 \n", []string{"620", "400"}},
+		{"short rows", "name,input\nPortal 2\nPortal,400\n", []string{"400"}},
+		{"empty", "", nil},
+	}
+	for _, c := range cases {
+		inputs, err := parseBatchCSV(strings.NewReader(c.body))
+		if err != nil || !slices.Equal(inputs, c.expected) {
+			t.Errorf("%s: expected %v, got %v, %v", c.name, c.expected, inputs, err)
+		}
+	}
+
+	if _, err := parseBatchCSV(strings.NewReader("\"620\n")); err == nil {
+		t.Error("expected an unterminated quote to fail")
+	}
+}
+
+func TestParseBatchNDJSON(t *testing.T) {
+	body := `"620"
+{"url": "https://store.steampowered.com/app/400/"}
+{"appId": "70"}
+{"url": "https://store.steampowered.com/app/220/", "appId": "220"}
+"  "
+`
+	expected := []string{"620", "https://store.steampowered.com/app/400/", "70", "https://store.steampowered.com/app/220/"}
+	inputs, err := parseBatchNDJSON(strings.NewReader(body))
+	if err != nil || !slices.Equal(inputs, expected) {
+		t.Errorf("expected %v, got %v, %v", expected, inputs, err)
+	}
+
+	invalid := map[string]string{
+		"broken json": "\"620\"\n{\"appId\": ",
+		"number":      "\"620\"\n620\n",
+		"array":       "[\"620\"]\n",
+	}
+	for name, body := range invalid {
+		_, err := parseBatchNDJSON(strings.NewReader(body))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	_, err = parseBatchNDJSON(strings.NewReader("\"620\"\n620\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected the error to name line 2, got %v", err)
+	}
+}
+
+func TestWriteBatchCSV(t *testing.T) {
+	components := steamrating.ComponentNames()
+	ratings := make([]steamrating.SteamPageSingleComponentRating, 0, len(components))
+	// reversed to check that scores are matched by name, not position
+	for i := len(components) - 1; i >= 0; i-- {
+		ratings = append(ratings, steamrating.SteamPageSingleComponentRating{Component: components[i], Score: 10 * (i + 1)})
+	}
+
+	results := []steamrating.BatchItemResult{
+		{
+			Input:  "620",
+			AppId:  "620",
+			Url:    "https://store.steampowered.com/app/620/",
+			Status: steamrating.BatchOk,
+			Result: &steamrating.SteamPageRatingResult{FinalWeightedScore: 72, ComponentRatings: ratings},
+		},
+		{Input: "portal", Status: steamrating.BatchError, Error: "not a steam app id, with a comma"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeBatchCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %q", buf.String())
+	}
+
+	header := "input,appId,url,status,finalWeightedScore," + strings.Join(components, ",") + ",error"
+	if lines[0] != header {
+		t.Errorf("expected header %q, got %q", header, lines[0])
+	}
+	if expected := "620,620,https://store.steampowered.com/app/620/,ok,72,10,20,30,40,50,60,"; lines[1] != expected {
+		t.Errorf("expected row %q, got %q", expected, lines[1])
+	}
+	if expected := "portal,,,error,,,,,,,,\"not a steam app id, with a comma\""; lines[2] != expected {
+		t.Errorf("expected row %q, got %q", expected, lines[2])
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gdrsapi/internal/gamedocgen"
+)
+
+// saveDesignDocVersion stores a generated document as a new version. Without
+// a document id a new stored document is created. Selection based
+// regenerations only return the changed sections, so those are merged into
+// the current document and checked against the template first.
+func (app *App) saveDesignDocVersion(documentId string, action string, template string, title string, currentDocument string, document interface{}, version gamedocgen.Version) (*gamedocgen.StoredDocument, *gamedocgen.Version, error) {
+	var content json.RawMessage
+	var err error
+
+	if action == gamedocgen.ActionRegenerate && version.Path == "" {
+		content, err = app.mergeRegeneration(currentDocument, document.(json.RawMessage), template)
+	} else {
+		content, err = json.Marshal(document)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	version.Document = content
+
+	if documentId == "" {
+		return app.designDocSvc.Create(template, title, version)
+	}
+	return app.designDocSvc.AddVersion(documentId, version)
+}
+
+// mergeRegeneration merges the sections of a selection based regeneration
+// into the current document and validates the result.
+func (app *App) mergeRegeneration(currentDocument string, sections json.RawMessage, template string) (json.RawMessage, error) {
+	tmpl, err := app.documentSvc.Templates().Get(template)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := gamedocgen.MergeSections([]byte(currentDocument), sections)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Schema().Validate(merged); err != nil {
+		return nil, fmt.Errorf("%w after the regeneration: %s", gamedocgen.ErrInvalidDocument, err)
+	}
+	return merged, nil
+}
+
+// designDocResponse is a stored document with the content of one version.
+type designDocResponse struct {
+	*gamedocgen.StoredDocument
+	Version *gamedocgen.Version `json:"version"`
+}
+
+func (app *App) getDesignDoc(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is GET
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	doc, version, err := app.designDocSvc.Current(req.PathValue("id"))
+	if err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+
+	if format := req.URL.Query().Get("format"); format != "" && format != gamedocgen.ExportJSON {
+		app.writeExportedDocument(w, req, version.Document, doc.Template, doc.Title, format)
+		return
+	}
+
+	apiResp.Result = designDocResponse{StoredDocument: doc, Version: version}
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) listDesignDocVersions(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is GET
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	versions, err := app.designDocSvc.Versions(req.PathValue("id"))
+	if err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+
+	apiResp.Result = versions
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) getDesignDocVersion(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is GET
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	n, err := strconv.Atoi(req.PathValue("version"))
+	if err != nil {
+		app.writeDesignDocError(w, req, gamedocgen.ErrVersionNotFound)
+		return
+	}
+
+	doc, err := app.designDocSvc.Get(req.PathValue("id"))
+	if err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+	version, err := app.designDocSvc.Version(doc.ID, n)
+	if err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+
+	if format := req.URL.Query().Get("format"); format != "" && format != gamedocgen.ExportJSON {
+		app.writeExportedDocument(w, req, version.Document, doc.Template, doc.Title, format)
+		return
+	}
+
+	apiResp.Result = version
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) revertDesignDoc(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is POST
+	if req.Method != http.MethodPost {
+		apiResp.ErrorMessage = "Only POST method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	// now check if we can process the request body
+	if err := req.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		apiResp.ErrorMessage = "Form body is too large"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	n, err := strconv.Atoi(req.PostFormValue("version"))
+	if err != nil {
+		apiResp.ErrorMessage = "version must be a version number"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	doc, version, err := app.designDocSvc.Revert(req.PathValue("id"), n)
+	if err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+
+	apiResp.Result = designDocResponse{StoredDocument: doc, Version: version}
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) diffDesignDoc(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is GET
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	from, fromErr := strconv.Atoi(req.URL.Query().Get("from"))
+	to, toErr := strconv.Atoi(req.URL.Query().Get("to"))
+	if fromErr != nil || toErr != nil {
+		apiResp.ErrorMessage = "from and to must be version numbers"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	changes, err := app.designDocSvc.Diff(req.PathValue("id"), from, to)
+	if err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+
+	apiResp.Result = changes
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+// writeDesignDocError answers 404 for missing documents and versions.
+func (app *App) writeDesignDocError(w http.ResponseWriter, req *http.Request, err error) {
+	apiResp := &ApiResponse{ErrorMessage: err.Error()}
+	status := http.StatusNotFound
+	if !errors.Is(err, gamedocgen.ErrDocumentNotFound) && !errors.Is(err, gamedocgen.ErrVersionNotFound) {
+		app.logger.ErrorContext(req.Context(), "loading design document", "err", err)
+		apiResp.ErrorMessage = "Error loading the design document"
+		status = http.StatusInternalServerError
+	}
+
+	err = app.encodeJsonResponse(w, apiResp, status)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) designDocChatHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		app.getDesignDocChat(w, req)
+	case http.MethodPost:
+		app.sendDesignDocChat(w, req)
+	case http.MethodDelete:
+		app.resetDesignDocChat(w, req)
+	default:
+		apiResp := &ApiResponse{ErrorMessage: "Only GET, POST and DELETE methods are allowed"}
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+	}
+}
+
+func (app *App) getDesignDocChat(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	session, err := app.chatSvc.Session(req.PathValue("id"))
+	if err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+
+	apiResp.Result = session
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) sendDesignDocChat(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// now check if we can process the request body
+	if err := req.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		apiResp.ErrorMessage = "Form body is too large"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	reply, err := app.chatSvc.Send(req.Context(), req.PathValue("id"), req.PostFormValue("message"))
+	if err != nil {
+		if errors.Is(err, gamedocgen.ErrDocumentNotFound) || errors.Is(err, gamedocgen.ErrVersionNotFound) {
+			app.writeDesignDocError(w, req, err)
+			return
+		}
+		status, message := llmErrorResponse(err, http.StatusBadRequest)
+		apiResp.ErrorMessage = message
+		err := app.encodeJsonResponse(w, apiResp, status)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	apiResp.Result = reply
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) resetDesignDocChat(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	if err := app.chatSvc.Reset(req.PathValue("id")); err != nil {
+		app.writeDesignDocError(w, req, err)
+		return
+	}
+
+	apiResp.Sucess = true
+	err := app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
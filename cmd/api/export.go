@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gdrsapi/internal/gamedocgen"
+)
+
+func (app *App) exportgdDocument(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is POST
+	if req.Method != http.MethodPost {
+		apiResp.ErrorMessage = "Only POST method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	// now check if we can process the request body
+	if err := req.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		apiResp.ErrorMessage = "Form body is too large"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	template := req.PostFormValue("template")
+	title := req.PostFormValue("title")
+	document := req.PostFormValue("document")
+	format := req.PostFormValue("format")
+
+	if template == "" || document == "" || format == "" {
+		apiResp.ErrorMessage = "template, document and format are required"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	if !json.Valid([]byte(document)) {
+		apiResp.ErrorMessage = "document must be valid json"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	app.writeExportedDocument(w, req, json.RawMessage(document), template, title, format)
+}
+
+// writeExportedDocument sends a design document as a file in the given format.
+func (app *App) writeExportedDocument(w http.ResponseWriter, req *http.Request, doc interface{}, template string, title string, format string) {
+	tmpl, err := app.documentSvc.Templates().Get(template)
+	if err != nil {
+		apiResp := &ApiResponse{ErrorMessage: err.Error()}
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	out, contentType, err := gamedocgen.ExportGameDesignDoc(doc, tmpl, title, format)
+	if err != nil {
+		apiResp := &ApiResponse{ErrorMessage: err.Error()}
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if format != gamedocgen.ExportHTML {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", gamedocgen.ExportFileName(title, format)))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(out); err != nil {
+		app.logger.ErrorContext(req.Context(), "writing exported document", "err", err)
+	}
+}
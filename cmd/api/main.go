@@ -5,21 +5,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
+	"gdrsapi/external/gemini"
 	"gdrsapi/external/gsheets"
+	"gdrsapi/internal/apikeys"
 	"gdrsapi/internal/gamedocgen"
+	"gdrsapi/internal/jobs"
 	"gdrsapi/internal/steamrating"
+	"gdrsapi/internal/usage"
+	"gdrsapi/internal/watchlist"
+	"gdrsapi/internal/webhooks"
+	"gdrsapi/pkg/clientip"
 	"gdrsapi/pkg/config"
 	"gdrsapi/pkg/limiter"
 	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/store"
 )
 
 func (app *App) healthCheck(w http.ResponseWriter, req *http.Request) {
@@ -31,10 +39,9 @@ func (s *App) encodeJsonResponse(w http.ResponseWriter, apiResp *ApiResponse, st
 	w.WriteHeader(statusCode)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(apiResp); err != nil {
-		s.logger.ErrorLog.Println(err.Error())
 		return fmt.Errorf("encode json: %w", err)
 	}
-	s.logger.InfoLog.Println("Sent json encoded response")
+	s.logger.Debug("sent json encoded response")
 	return nil
 }
 
@@ -50,7 +57,7 @@ func (app *App) generategdDocument(w http.ResponseWriter, req *http.Request) {
 		apiResp.ErrorMessage = "Only POST method is allowed"
 		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
 		if err != nil {
-			app.logger.ErrorLog.Println(err.Error())
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
@@ -60,7 +67,7 @@ func (app *App) generategdDocument(w http.ResponseWriter, req *http.Request) {
 		apiResp.ErrorMessage = "Form body is too large"
 		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
 		if err != nil {
-			app.logger.ErrorLog.Println(err.Error())
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
@@ -75,10 +82,50 @@ func (app *App) generategdDocument(w http.ResponseWriter, req *http.Request) {
 	// these are optional for regeneration action
 	suggestion := formData.Get("suggestion")
 	selection := formData.Get("selection")
+	// a path such as coreMechanics[3] regenerates just that value
+	path := formData.Get("path")
+	// with a stored document, generations become its next version and
+	// regenerations edit its current version
+	documentId := formData.Get("documentId")
 
 	// if you have a selection, do you need a suggestion?
 	if suggestion != "" && selection != "" {
-		app.logger.InfoLog.Println("suggestion and selection provided")
+		app.logger.InfoContext(req.Context(), "suggestion and selection provided")
+	}
+
+	if documentId != "" {
+		stored, current, err := app.designDocSvc.Current(documentId)
+		if err != nil {
+			status := http.StatusInternalServerError
+			apiResp.ErrorMessage = "Error loading the design document"
+			if errors.Is(err, gamedocgen.ErrDocumentNotFound) {
+				status = http.StatusNotFound
+				apiResp.ErrorMessage = err.Error()
+			} else {
+				app.logger.ErrorContext(req.Context(), "loading design document", "documentId", documentId, "err", err)
+			}
+			err := app.encodeJsonResponse(w, apiResp, status)
+			if err != nil {
+				app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+			}
+			return
+		}
+
+		if template == "" {
+			template = stored.Template
+		}
+		if template != stored.Template {
+			apiResp.ErrorMessage = fmt.Sprintf("the document uses the %s template", stored.Template)
+			err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+			if err != nil {
+				app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+			}
+			return
+		}
+		if gameTitle == "" {
+			gameTitle = stored.Title
+		}
+		currentDocumentJsonString = string(current.Document)
 	}
 
 	//validate action and template are present
@@ -86,7 +133,16 @@ func (app *App) generategdDocument(w http.ResponseWriter, req *http.Request) {
 		apiResp.ErrorMessage = "both action and template are required"
 		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
 		if err != nil {
-			app.logger.ErrorLog.Println(err.Error())
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	if format := formData.Get("format"); format != "" && !gamedocgen.ValidExportFormat(format) {
+		apiResp.ErrorMessage = gamedocgen.ErrUnknownExportFormat.Error()
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
@@ -96,7 +152,7 @@ func (app *App) generategdDocument(w http.ResponseWriter, req *http.Request) {
 		apiResp.ErrorMessage = "Required fields are missing"
 		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
 		if err != nil {
-			app.logger.ErrorLog.Println(err.Error())
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
@@ -105,35 +161,89 @@ func (app *App) generategdDocument(w http.ResponseWriter, req *http.Request) {
 		apiResp.ErrorMessage = "Existing document is required for regeneration"
 		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
 		if err != nil {
-			app.logger.ErrorLog.Println(err.Error())
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
 
 	var fResp interface{}
+	var document interface{}
 	var err error
 
-	switch action {
-	case "generate":
-		fResp, err = app.documentSvc.GenerateGameDesignDoc(gameTitle, gameDescription, gameGenre, template)
-	case "regenerate":
-		fResp, err = app.documentSvc.RegenerateGameDesignDoc(currentDocumentJsonString, selection, suggestion, template)
+	switch {
+	case action == "generate":
+		fResp, err = app.documentSvc.GenerateGameDesignDoc(req.Context(), gameTitle, gameDescription, gameGenre, template)
+		document = fResp
+	case action == "regenerate" && path != "":
+		var regen *gamedocgen.SectionRegeneration
+		regen, err = app.documentSvc.RegenerateSection(req.Context(), currentDocumentJsonString, path, suggestion, template)
+		if err == nil {
+			fResp, document = regen, regen.Document
+		}
+	case action == "regenerate":
+		fResp, err = app.documentSvc.RegenerateGameDesignDoc(req.Context(), currentDocumentJsonString, selection, suggestion, template)
+		document = fResp
 	}
 
 	if err != nil {
-		apiResp.ErrorMessage = err.Error()
-		err = app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		status, message := llmErrorResponse(err, http.StatusBadRequest)
+		apiResp.ErrorMessage = message
+		err = app.encodeJsonResponse(w, apiResp, status)
 		if err != nil {
-			app.logger.ErrorLog.Println(err.Error())
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
 
+	// every generation is kept as a new version of a stored document
+	stored, version, err := app.saveDesignDocVersion(documentId, action, template, gameTitle, currentDocumentJsonString, document, gamedocgen.Version{
+		Action:     action,
+		Prompt:     gameDescription,
+		Suggestion: suggestion,
+		Selection:  selection,
+		Path:       path,
+	})
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "saving design document", "err", err)
+		status := http.StatusInternalServerError
+		apiResp.ErrorMessage = "Error saving the design document"
+		if errors.Is(err, gamedocgen.ErrInvalidDocument) {
+			status = http.StatusBadGateway
+			apiResp.ErrorMessage = "The model's answer did not fit the document, please try again: " + err.Error()
+		}
+		err := app.encodeJsonResponse(w, apiResp, status)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+	if action == "regenerate" && path == "" {
+		// the reply only holds the changed sections, the version has them all
+		document = version.Document
+	}
+	w.Header().Set("X-Design-Doc-Id", stored.ID)
+	w.Header().Set("X-Design-Doc-Version", strconv.Itoa(version.Number))
+
+	app.webhookSvc.Dispatch(req.Context(), webhooks.EventDesignDocGenerated, designDocGeneratedEvent{
+		Action:     action,
+		Template:   template,
+		Title:      gameTitle,
+		DocumentId: stored.ID,
+		Version:    version.Number,
+		Document:   document,
+	})
+
+	// any format other than json sends the document back as a file
+	if format := formData.Get("format"); format != "" && format != gamedocgen.ExportJSON {
+		app.writeExportedDocument(w, req, document, template, gameTitle, format)
+		return
+	}
+
 	apiResp.Result = fResp
 	apiResp.Sucess = true
 	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
 	if err != nil {
-		app.logger.ErrorLog.Println(err.Error())
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 	}
 }
 
@@ -149,7 +259,7 @@ func (s *App) getSteamRating(w http.ResponseWriter, req *http.Request) {
 		apiResp.ErrorMessage = "Only POST method is allowed"
 		err := s.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
 		if err != nil {
-			s.logger.ErrorLog.Println(err.Error())
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
@@ -159,50 +269,122 @@ func (s *App) getSteamRating(w http.ResponseWriter, req *http.Request) {
 		apiResp.ErrorMessage = "Form body is too large"
 		err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
 		if err != nil {
-			s.logger.ErrorLog.Println(err.Error())
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
 
 	// get url and validate it
-	const steamBaseUrl = "store.steampowered.com"
-
-	steamUrl := req.PostFormValue("url")
-	if steamUrl == "" || steamUrl == " " {
+	steamUrl := strings.TrimSpace(req.PostFormValue("url"))
+	if steamUrl == "" {
 		apiResp.ErrorMessage = "Steam Url is required"
 		err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
 		if err != nil {
-			s.logger.ErrorLog.Println(err.Error())
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
 
-	//grab individual meta data from steam url
-	steamPageParts := strings.Split(steamUrl, "//")[1]
-	baseUrl := strings.Split(steamPageParts, "/")[0]
-	gameAppId := strings.Split(steamPageParts, "/")[2]
-	gameTitle := strings.Split(steamPageParts, "/")[3]
-
-	if baseUrl != steamBaseUrl {
-		apiResp.ErrorMessage = "Steam page Url is invalid"
+	gameAppId, err := steamrating.ParseSteamAppId(steamUrl)
+	if err != nil {
+		apiResp.ErrorMessage = "Steam page Url is invalid: " + err.Error()
 		err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
 		if err != nil {
-			s.logger.ErrorLog.Println(err.Error())
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
 
-	//scrape and parse html for steam page content
-	steamPgContent, err := s.scrapingSvc.ScrapeSteamPage(steamUrl)
+	// the title is taken from the scraped page
+	fResp, err := s.rateSteamPage(req.Context(), steamrating.SteamAppUrl(gameAppId), "", gameAppId)
 	if err != nil {
-		apiResp.ErrorMessage = "Error scraping and parsing steam page"
-		err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		status, message := llmErrorResponse(err, http.StatusBadRequest)
+		apiResp.ErrorMessage = message
+		if errors.Is(err, errScrapeSteamPage) {
+			apiResp.ErrorMessage = errScrapeSteamPage.Error()
+		}
+		err = s.encodeJsonResponse(w, apiResp, status)
 		if err != nil {
-			s.logger.ErrorLog.Println(err.Error())
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
 
+	apiResp.Result = fResp
+	apiResp.Sucess = true
+	err = s.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+var errScrapeSteamPage = errors.New("Error scraping and parsing steam page")
+
+// llmErrorResponse maps gemini errors to a status and a message the client
+// can act on. Other errors get the fallback status and their own message.
+func llmErrorResponse(err error, fallback int) (int, string) {
+	switch {
+	case errors.Is(err, gemini.ErrBlocked):
+		return http.StatusUnprocessableEntity, "The request was blocked by the model's safety filters, try rephrasing it: " + err.Error()
+	case errors.Is(err, gemini.ErrTruncated):
+		return http.StatusBadGateway, "The model's answer was cut off before it was complete, try a shorter request: " + err.Error()
+	case errors.Is(err, gemini.ErrEmptyResponse):
+		return http.StatusBadGateway, "The model returned an empty answer, please try again"
+	case errors.Is(err, gemini.ErrRateLimited):
+		return http.StatusServiceUnavailable, "The model is receiving too many requests, please try again later"
+	case errors.Is(err, usage.ErrBudgetExceeded):
+		return http.StatusServiceUnavailable, "The daily usage limit has been reached, please try again tomorrow"
+	}
+	return fallback, err.Error()
+}
+
+// webhook payloads sent by the api handlers
+type ratingCompletedEvent struct {
+	AppId  string                             `json:"appId"`
+	Url    string                             `json:"url"`
+	Title  string                             `json:"title"`
+	Rating *steamrating.SteamPageRatingResult `json:"rating"`
+}
+
+type ratingFailedEvent struct {
+	AppId string `json:"appId"`
+	Url   string `json:"url"`
+	Title string `json:"title"`
+	Error string `json:"error"`
+}
+
+type designDocGeneratedEvent struct {
+	Action     string      `json:"action"`
+	Template   string      `json:"template"`
+	Title      string      `json:"title,omitempty"`
+	DocumentId string      `json:"documentId"`
+	Version    int         `json:"version"`
+	Document   interface{} `json:"document"`
+}
+
+// rateSteamPage runs the rating pipeline for a steam page: scrape, rate and
+// record the rating with what it cost. The title falls back to the scraped
+// one when empty.
+func (s *App) rateSteamPage(ctx context.Context, steamUrl string, gameTitle string, gameAppId string) (*steamrating.SteamPageRatingResult, error) {
+	ctx, spent := usage.Collect(ctx)
+
+	//scrape and parse html for steam page content
+	steamPgContent, err := s.scrapingSvc.ScrapeSteamPage(ctx, steamUrl)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "scraping steam page", "url", steamUrl, "err", err)
+		s.webhookSvc.Dispatch(ctx, webhooks.EventRatingFailed, ratingFailedEvent{
+			AppId: gameAppId,
+			Url:   steamUrl,
+			Title: gameTitle,
+			Error: errScrapeSteamPage.Error(),
+		})
+		return nil, fmt.Errorf("%w: %s", errScrapeSteamPage, err)
+	}
+
+	if gameTitle == "" {
+		gameTitle = steamPgContent.Title
+	}
+
 	se := &gsheets.SheetsEntry{
 		Title:      gameTitle,
 		AppId:      gameAppId,
@@ -210,23 +392,130 @@ func (s *App) getSteamRating(w http.ResponseWriter, req *http.Request) {
 		PromptType: "default",
 	}
 
-	fResp, err := s.ratingSvc.GetSteamPageRating(*steamPgContent, se)
+	fResp, err := s.ratingSvc.GetSteamPageRating(ctx, *steamPgContent, se)
 	if err != nil {
-		apiResp.ErrorMessage = err.Error()
-		err = s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		s.webhookSvc.Dispatch(ctx, webhooks.EventRatingFailed, ratingFailedEvent{
+			AppId: gameAppId,
+			Url:   steamUrl,
+			Title: gameTitle,
+			Error: err.Error(),
+		})
+		return nil, err
+	}
+
+	go func(ctx context.Context, se gsheets.SheetsEntry) {
+		if err := s.sheetsSvc.InsertSteamRatingEntry(ctx, se); err != nil {
+			s.logger.ErrorContext(ctx, "inserting rating into the sheet", "err", err)
+		}
+	}(context.WithoutCancel(ctx), *se)
+
+	// the rating is still returned when it can not be kept, it just has no id
+	if _, err := s.historySvc.Save(gameAppId, steamUrl, gameTitle, fResp, spent.Totals()); err != nil {
+		s.logger.ErrorContext(ctx, "saving rating", "appId", gameAppId, "err", err)
+	}
+
+	s.webhookSvc.Dispatch(ctx, webhooks.EventRatingCompleted, ratingCompletedEvent{
+		AppId:  gameAppId,
+		Url:    steamUrl,
+		Title:  gameTitle,
+		Rating: fResp,
+	})
+	return fResp, nil
+}
+
+func (s *App) benchmarkSteamPage(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is POST
+	if req.Method != http.MethodPost {
+		apiResp.ErrorMessage = "Only POST method is allowed"
+		err := s.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
 		if err != nil {
-			s.logger.ErrorLog.Println(err.Error())
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 		}
 		return
 	}
 
-	go s.sheetsSvc.InsertSteamRatingEntry(*se)
+	// now check if we can process the request body
+	if err := req.ParseMultipartForm(10 << 20); err != nil {
+		apiResp.ErrorMessage = "Form body is too large"
+		err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	// the target can be sent as a store url or an app id
+	target := req.PostFormValue("url")
+	if target == "" {
+		target = req.PostFormValue("appId")
+	}
+
+	appId, err := steamrating.ParseSteamAppId(target)
+	if err != nil {
+		apiResp.ErrorMessage = "A valid steam url or app id is required"
+		err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	var competitorIds []string
+	for _, c := range strings.Split(req.PostFormValue("competitors"), ",") {
+		if strings.TrimSpace(c) == "" {
+			continue
+		}
+		competitorId, err := steamrating.ParseSteamAppId(c)
+		if err != nil {
+			apiResp.ErrorMessage = err.Error()
+			err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+			if err != nil {
+				s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+			}
+			return
+		}
+		competitorIds = append(competitorIds, competitorId)
+	}
+
+	maxCompetitors := 0
+	if v := req.PostFormValue("maxCompetitors"); v != "" {
+		maxCompetitors, err = strconv.Atoi(v)
+		if err != nil || maxCompetitors < 1 {
+			apiResp.ErrorMessage = "maxCompetitors must be a positive number"
+			err := s.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+			if err != nil {
+				s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+			}
+			return
+		}
+	}
+
+	fResp, err := s.benchmarkSvc.Benchmark(req.Context(), steamrating.BenchmarkRequest{
+		AppId:          appId,
+		CompetitorIds:  competitorIds,
+		MaxCompetitors: maxCompetitors,
+	})
+	if err != nil {
+		status, message := llmErrorResponse(err, http.StatusBadRequest)
+		apiResp.ErrorMessage = message
+		err = s.encodeJsonResponse(w, apiResp, status)
+		if err != nil {
+			s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
 
 	apiResp.Result = fResp
 	apiResp.Sucess = true
 	err = s.encodeJsonResponse(w, apiResp, http.StatusOK)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
+		s.logger.ErrorContext(req.Context(), "writing json response", "err", err)
 	}
 }
 
@@ -237,14 +526,25 @@ type ApiResponse struct {
 }
 
 type App struct {
-	scrapingSvc *steamrating.SteamScraper
-	ratingSvc   *steamrating.SteamRater
-	sheetsSvc   *gsheets.SheetsApp
-	documentSvc *gamedocgen.GameDesignDocGen
-	logger      *logger.AppLogger
-	mu          *sync.Mutex
-	limiter     *limiter.Limiter
-	cfg         *config.Config
+	scrapingSvc  *steamrating.SteamScraper
+	ratingSvc    *steamrating.SteamRater
+	benchmarkSvc *steamrating.SteamBenchmarker
+	batchSvc     *steamrating.BatchRater
+	jobs         *jobs.Manager
+	watchlistSvc *watchlist.Watchlist
+	webhookSvc   *webhooks.Dispatcher
+	historySvc   *steamrating.RatingHistory
+	reportSvc    *steamrating.ReportRenderer
+	sheetsSvc    *gsheets.SheetsApp
+	documentSvc  *gamedocgen.GameDesignDocGen
+	designDocSvc *gamedocgen.DocumentStore
+	chatSvc      *gamedocgen.ChatService
+	usageSvc     *usage.Tracker
+	apiKeySvc    *apikeys.KeyStore
+	logger       *logger.AppLogger
+	limiter      *limiter.Limiter
+	clientIps    *clientip.Resolver
+	cfg          *config.Config
 }
 
 func newApp() *App {
@@ -252,25 +552,108 @@ func newApp() *App {
 
 	cfg, err := config.GetConfig()
 	if err != nil {
-		AppLogger.ErrorLog.Fatal(err.Error())
+		AppLogger.Error("loading config", "err", err)
+		os.Exit(1)
 	}
 
-	limiter := limiter.NewLimiter()
+	// the configured logger takes over once the config and its secrets are
+	// known, the external clients log through the default one
+	AppLogger = logger.New(logger.Options{
+		Level:   cfg.LogLevel,
+		Format:  cfg.LogFormat,
+		Secrets: []string{cfg.GeminiApiKey, cfg.CloudflareApiKey, cfg.GoogleSACred, cfg.AdminToken, cfg.WebhookSecret},
+	})
+	slog.SetDefault(AppLogger.Logger)
+
 	scrapingSvc := steamrating.NewSteamScraper(AppLogger)
 	ratingSvc := steamrating.NewSteamRater(AppLogger)
-	gdDocGen := gamedocgen.NewgdDocGen(AppLogger)
+	benchmarkSvc := steamrating.NewSteamBenchmarker(AppLogger, scrapingSvc, ratingSvc)
 	sheetSvc := gsheets.NewSheetsService()
 
-	return &App{
-		scrapingSvc: scrapingSvc,
-		ratingSvc:   ratingSvc,
-		sheetsSvc:   sheetSvc,
-		documentSvc: gdDocGen,
-		logger:      AppLogger,
-		mu:          &sync.Mutex{},
-		limiter:     limiter,
-		cfg:         cfg,
+	templates, err := gamedocgen.LoadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		AppLogger.Error("loading templates", "err", err)
+		os.Exit(1)
+	}
+	gdDocGen := gamedocgen.NewgdDocGen(AppLogger, templates)
+
+	dataStore, err := store.New(cfg.DataDir)
+	if err != nil {
+		AppLogger.Error("opening data store", "err", err)
+		os.Exit(1)
 	}
+
+	policies, err := rateLimitPolicies(cfg.RateLimitPolicies)
+	if err != nil {
+		AppLogger.Error("parsing RATE_LIMIT_POLICIES", "err", err)
+		os.Exit(1)
+	}
+	trustedProxies, err := clientip.ParseTrusted(cfg.TrustedProxies)
+	if err != nil {
+		AppLogger.Error("parsing TRUSTED_PROXIES", "err", err)
+		os.Exit(1)
+	}
+	var buckets limiter.Store
+	switch cfg.RateLimitStore {
+	case "file":
+		buckets = limiter.NewFileStore(dataStore)
+	case "memory":
+		buckets = limiter.NewMemoryStore()
+	default:
+		AppLogger.Error("unknown rate limit store, use file or memory", "store", cfg.RateLimitStore)
+		os.Exit(1)
+	}
+
+	endpoints := webhooks.ParseEndpoints(cfg.WebhookUrls)
+	if cfg.WatchlistWebhookUrl != "" {
+		endpoints = append(endpoints, webhooks.Endpoint{
+			Url:    cfg.WatchlistWebhookUrl,
+			Events: []string{webhooks.EventWatchlistChanged},
+		})
+	}
+	dispatcher, err := webhooks.NewDispatcher(AppLogger, endpoints, cfg.WebhookSecret, cfg.WebhookDeadLetter)
+	if err != nil {
+		AppLogger.Error("creating webhook dispatcher, set WEBHOOK_SECRET", "err", err)
+		os.Exit(1)
+	}
+
+	notifier := watchlist.NotifierFunc(func(ctx context.Context, event watchlist.ChangeEvent) error {
+		dispatcher.Dispatch(ctx, webhooks.EventWatchlistChanged, event)
+		return nil
+	})
+	watchlistSvc := watchlist.NewWatchlist(AppLogger, dataStore, scrapingSvc, ratingSvc, notifier)
+	designDocSvc := gamedocgen.NewDocumentStore(dataStore)
+
+	app := &App{
+		scrapingSvc:  scrapingSvc,
+		ratingSvc:    ratingSvc,
+		benchmarkSvc: benchmarkSvc,
+		watchlistSvc: watchlistSvc,
+		webhookSvc:   dispatcher,
+		historySvc:   steamrating.NewRatingHistory(dataStore),
+		reportSvc:    steamrating.NewReportRenderer(AppLogger),
+		sheetsSvc:    sheetSvc,
+		documentSvc:  gdDocGen,
+		designDocSvc: designDocSvc,
+		chatSvc:      gamedocgen.NewChatService(gdDocGen, designDocSvc, dataStore),
+		usageSvc: usage.NewTracker(dataStore, usage.Prices{
+			GeminiInputPerMillion:        cfg.GeminiInputCostPerMillion,
+			GeminiOutputPerMillion:       cfg.GeminiOutputCostPerMillion,
+			CloudflarePerThousandNeurons: cfg.CloudflareCostPerThousandNeurons,
+			CloudflareNeuronsPerCall:     cfg.CloudflareNeuronsPerCall,
+		}, cfg.DailySpendLimit),
+		apiKeySvc: apikeys.NewKeyStore(dataStore),
+		logger:    AppLogger,
+		limiter:   limiter.NewLimiter(buckets, policies),
+		clientIps: clientip.NewResolver(trustedProxies),
+		cfg:       cfg,
+		jobs:      jobs.NewManager(24 * time.Hour),
+	}
+	app.batchSvc = steamrating.NewBatchRater(AppLogger, func(ctx context.Context, appId string) (*steamrating.SteamPageRatingResult, error) {
+		return app.rateSteamPage(ctx, steamrating.SteamAppUrl(appId), "", appId)
+	}, cfg.BatchConcurrency, cfg.BatchRatePerMinute)
+
+	return app
 }
 
 func enableCORS(next http.HandlerFunc) http.HandlerFunc {
@@ -284,8 +667,9 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 			}
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Design-Doc-Id, X-Design-Doc-Version, Content-Disposition, Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Request-Id")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -297,51 +681,92 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 }
 
 // Middlewares
-func (s *App) logRequestMidleware(next http.Handler) http.Handler {
+
+// withRequestId tags every request with an id, the one sent in X-Request-Id
+// or a new one. It is returned in the same header and added to every log
+// line written with the request's context.
+func (app *App) withRequestId(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s.logger.InfoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
-		next.ServeHTTP(w, r)
+		id := r.Header.Get("X-Request-Id")
+		if !validRequestId(id) {
+			id = logger.NewRequestID()
+		}
+		w.Header().Set("X-Request-Id", id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
 	})
 }
 
-func (app *App) rateLimitMiddleware(next http.Handler) http.Handler {
-	if app.cfg.Environment == "DEV" {
-		app.logger.InfoLog.Println("Rate limiting is disabled in dev mode")
-		return next
+// validRequestId accepts the ids of proxies and clients that trace their
+// own requests, as long as they are short and safe to log.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
 	}
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			app.logger.ErrorLog.Println(err.Error())
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
 		}
-		app.mu.Lock()
+	}
+	return true
+}
 
-		if !app.limiter.ClientAllowed(ip) {
-			app.mu.Unlock()
-			app.logger.InfoLog.Printf("Clisent %s is rate limited", ip)
-			w.WriteHeader(http.StatusTooManyRequests)
-			w.Write([]byte("Rate limit exceeded"))
-			return
-		}
+// statusRecorder keeps the status a handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
 
-		app.mu.Unlock()
-		app.logger.InfoLog.Printf("Client %s is allowed", ip)
-		next.ServeHTTP(w, r)
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (s *App) logRequestMidleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		s.logger.InfoContext(r.Context(), "request",
+			"ip", clientip.FromRequest(r),
+			"proto", r.Proto,
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"status", rec.status,
+			"durationMs", time.Since(start).Milliseconds(),
+		)
 	})
 }
 
-func (app *App) checkRateLimitClient() {
+func (app *App) removeExpiredRateLimits() {
 	go func() {
-		app.logger.InfoLog.Println("Starting remove clients routine")
-
 		for {
-			time.Sleep(time.Second * 5)
-			app.logger.InfoLog.Println("Removing clients")
+			time.Sleep(time.Minute)
+			if err := app.limiter.RemoveExpired(); err != nil {
+				app.logger.Error("removing expired rate limits", "err", err)
+			}
+		}
+	}()
+}
 
-			app.mu.Lock()
-			app.limiter.RemoveClients()
-			app.mu.Unlock()
+func (app *App) removeExpiredJobs() {
+	go func() {
+		for {
+			time.Sleep(time.Hour)
+			app.jobs.RemoveExpired()
 		}
 	}()
 }
@@ -349,8 +774,31 @@ func (app *App) checkRateLimitClient() {
 func (app *App) mapRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/getsteamrating", enableCORS(app.getSteamRating))
-	mux.HandleFunc("/gengamedesigndoc", enableCORS(app.generategdDocument))
+	// public routes take an optional api key
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, enableCORS(app.withApiKey(app.rateLimited(handler))))
+	}
+
+	handle("/getsteamrating", app.metered(app.getSteamRating))
+	handle("/gengamedesigndoc", app.metered(app.generategdDocument))
+	handle("/exportgamedesigndoc", app.exportgdDocument)
+	handle("/templates", app.listTemplates)
+	handle("/templates/{name}", app.getTemplate)
+	handle("/designdocs/{id}", app.getDesignDoc)
+	handle("/designdocs/{id}/versions", app.listDesignDocVersions)
+	handle("/designdocs/{id}/versions/{version}", app.getDesignDocVersion)
+	handle("/designdocs/{id}/revert", app.revertDesignDoc)
+	handle("/designdocs/{id}/diff", app.diffDesignDoc)
+	handle("/designdocs/{id}/chat", app.metered(app.designDocChatHandler))
+	handle("/steamratings/benchmark", app.metered(app.benchmarkSteamPage))
+	handle("/steamratings/batch", app.metered(app.createSteamRatingBatch))
+	handle("/steamratings/{id}/{resource}", app.steamRatingResource)
+	handle("/jobs/{id}", app.getJob)
+	handle("/watchlist", app.watchlistHandler)
+	handle("/watchlist/{appId}", app.removeFromWatchlist)
+	mux.HandleFunc("/metrics/usage", app.adminOnly(app.getUsage))
+	mux.HandleFunc("/admin/keys", app.adminOnly(app.apiKeysHandler))
+	mux.HandleFunc("/admin/keys/{id}", app.adminOnly(app.apiKeyHandler))
 	mux.HandleFunc("/", app.healthCheck)
 
 	return mux
@@ -359,7 +807,7 @@ func (app *App) mapRoutes() *http.ServeMux {
 func (app *App) serve() error {
 	svc := &http.Server{
 		Addr:    ":8082",
-		Handler: app.logRequestMidleware(app.rateLimitMiddleware(app.mapRoutes())),
+		Handler: app.clientIps.Handler(app.withRequestId(app.logRequestMidleware(app.mapRoutes()))),
 	}
 
 	shutdownErr := make(chan error)
@@ -368,15 +816,29 @@ func (app *App) serve() error {
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		s := <-quit
 
-		app.logger.InfoLog.Printf("Received signal %s. Shutting down server", s)
+		app.logger.Info("shutting down server", "signal", s.String())
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		shutdownErr <- svc.Shutdown(ctx)
-		app.logger.InfoLog.Println("Server shutdown successfully")
+		err := svc.Shutdown(ctx)
+
+		// webhooks of the last requests are still being delivered
+		delivered := make(chan struct{})
+		go func() {
+			app.webhookSvc.Wait()
+			close(delivered)
+		}()
+		select {
+		case <-delivered:
+		case <-ctx.Done():
+			app.logger.Error("webhook deliveries did not finish before shutdown")
+		}
+
+		shutdownErr <- err
+		app.logger.Info("server shutdown successfully")
 	}()
 
-	app.logger.InfoLog.Printf("Starting server on port%s", svc.Addr)
+	app.logger.Info("starting server", "addr", svc.Addr)
 	err := svc.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
@@ -387,15 +849,21 @@ func (app *App) serve() error {
 		return err
 	}
 
-	app.logger.InfoLog.Println("Stoped server")
+	app.logger.Info("stopped server")
 	return nil
 }
 
 func main() {
 	app := newApp()
+	app.removeExpiredJobs()
+	app.removeExpiredRateLimits()
+	watchlistCtx, stopWatchlist := context.WithCancel(app.backgroundContext("watchlist"))
+	app.watchlistSvc.Start(watchlistCtx, time.Minute)
 
 	err := app.serve()
+	stopWatchlist()
 	if err != nil {
-		app.logger.ErrorLog.Fatal(err.Error())
+		app.logger.Error("serving", "err", err)
+		os.Exit(1)
 	}
 }
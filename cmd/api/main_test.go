@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gdrsapi/pkg/logger"
+)
+
+func TestGetSteamRatingInvalidUrl(t *testing.T) {
+	app := &App{logger: logger.New(logger.Options{Output: io.Discard})}
+
+	for _, steamUrl := range []string{
+		"https://store.steampowered.com",
+		"https://store.steampowered.com/app/",
+		"https://example.com/app/620/Portal_2/",
+		"store.steampowered.com/app/620",
+		"portal",
+	} {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("url", steamUrl)
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/getsteamrating", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		app.getSteamRating(w, req)
+
+		var resp ApiResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusBadRequest || resp.Sucess || resp.ErrorMessage == "" {
+			t.Errorf("%s: expected a 400 with an error, got %d %+v", steamUrl, w.Code, resp)
+		}
+	}
+}
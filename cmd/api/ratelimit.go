@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gdrsapi/internal/steamrating"
+	"gdrsapi/pkg/clientip"
+	"gdrsapi/pkg/limiter"
+)
+
+// Anonymous clients get a bucket per policy. Routes that call the models
+// spend one token per model call, everything else one per request.
+const (
+	ratingPolicy    = "rating"
+	designDocPolicy = "designdoc"
+	defaultPolicy   = "default"
+)
+
+var defaultRateLimitPolicies = []limiter.Policy{
+	{Name: ratingPolicy, Limit: 10 * steamrating.CallsPerRating, Window: 24 * time.Hour},
+	{Name: designDocPolicy, Limit: 60, Window: 24 * time.Hour},
+	{Name: defaultPolicy, Limit: 600, Window: time.Hour},
+}
+
+// rateLimitPolicies returns the default policies with the ones set in
+// RATE_LIMIT_POLICIES in their place.
+func rateLimitPolicies(spec string) ([]limiter.Policy, error) {
+	overrides, err := limiter.ParsePolicies(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := slices.Clone(defaultRateLimitPolicies)
+	for _, o := range overrides {
+		i := slices.IndexFunc(policies, func(p limiter.Policy) bool { return p.Name == o.Name })
+		if i < 0 {
+			return nil, fmt.Errorf("%w %q", limiter.ErrUnknownPolicy, o.Name)
+		}
+		policies[i] = o
+	}
+	return policies, nil
+}
+
+// routeLimit returns the policy a request spends and its cost. Requests the
+// handler refuses before calling a model, such as a GET to a POST route, are
+// free.
+func (app *App) routeLimit(req *http.Request) (string, int) {
+	var policy string
+	switch req.Pattern {
+	case "/getsteamrating", "/steamratings/benchmark", "/steamratings/batch", "/watchlist":
+		policy = ratingPolicy
+	case "/gengamedesigndoc", "/designdocs/{id}/chat":
+		policy = designDocPolicy
+	default:
+		return defaultPolicy, 1
+	}
+	if req.Method != http.MethodPost {
+		return policy, 0
+	}
+
+	switch req.Pattern {
+	case "/getsteamrating":
+		return policy, steamrating.CallsPerRating
+	case "/watchlist":
+		// the baseline of a watched app is a rating
+		return policy, steamrating.CallsPerRating
+	case "/steamratings/benchmark":
+		return policy, benchmarkCost(req)
+	case "/steamratings/batch":
+		// the size of a batch is only known once the handler read the body,
+		// it spends the tokens itself
+		return policy, 0
+	case "/gengamedesigndoc":
+		return policy, app.designDocCost(req)
+	}
+	return policy, 1
+}
+
+func benchmarkCost(req *http.Request) int {
+	// parse errors are answered by the handler
+	_ = req.ParseMultipartForm(10 << 20)
+
+	benchmark := steamrating.BenchmarkRequest{}
+	for _, c := range strings.Split(req.PostFormValue("competitors"), ",") {
+		if strings.TrimSpace(c) != "" {
+			benchmark.CompetitorIds = append(benchmark.CompetitorIds, c)
+		}
+	}
+	benchmark.MaxCompetitors, _ = strconv.Atoi(req.PostFormValue("maxCompetitors"))
+	return benchmark.Ratings() * steamrating.CallsPerRating
+}
+
+// designDocCost is one call for a regeneration or a template that is
+// generated at once, and a call per section for sectioned templates.
+func (app *App) designDocCost(req *http.Request) int {
+	_ = req.ParseMultipartForm(10 << 20)
+
+	if req.PostFormValue("action") != "generate" {
+		return 1
+	}
+	tmpl, err := app.documentSvc.Templates().Get(req.PostFormValue("template"))
+	if err != nil {
+		return 1
+	}
+	return tmpl.GenerateCalls()
+}
+
+// rateLimited spends the cost of the request from the client's bucket for
+// the route's policy.
+func (app *App) rateLimited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		policy, cost := app.routeLimit(req)
+		if !app.takeRateLimit(w, req, policy, cost) {
+			return
+		}
+		next(w, req)
+	}
+}
+
+// takeRateLimit spends cost tokens of the policy and sets the X-RateLimit
+// headers. It answers 429 and returns false when the bucket can not cover
+// the cost. Requests with an api key spend the cost from the key's limits
+// instead, and anonymous requests are not limited in dev mode.
+func (app *App) takeRateLimit(w http.ResponseWriter, req *http.Request, policy string, cost int) bool {
+	if key, ok := requestKey(req); ok {
+		return app.allowApiKey(w, req, key, cost)
+	}
+	if app.cfg.Environment == "DEV" {
+		return true
+	}
+
+	ip := clientip.FromRequest(req)
+	res, err := app.limiter.Take(policy, ip, cost)
+	if err != nil {
+		// a missing policy or a failing store must not lock clients out
+		app.logger.ErrorContext(req.Context(), "taking rate limit", "policy", policy, "err", err)
+		return true
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", headerSeconds(res.Reset))
+	if res.Allowed {
+		return true
+	}
+
+	app.logger.InfoContext(req.Context(), "client is rate limited", "ip", ip, "policy", policy, "cost", res.Cost)
+	apiResp := &ApiResponse{ErrorMessage: fmt.Sprintf("Rate limit exceeded, this request costs %d of the %d %s tokens", res.Cost, res.Limit, policy)}
+	if res.RetryAfter > 0 {
+		w.Header().Set("Retry-After", headerSeconds(res.RetryAfter))
+	}
+	err = app.encodeJsonResponse(w, apiResp, http.StatusTooManyRequests)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+	return false
+}
+
+// headerSeconds formats a duration as whole seconds, rounded up.
+func headerSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
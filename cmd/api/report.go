@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gdrsapi/internal/steamrating"
+)
+
+// steamRatingResource serves /steamratings/{id}/{resource}. Batches share the
+// /steamratings prefix and a pattern of their own would conflict with this
+// one, so /steamratings/batch/{id} is routed from here too.
+func (app *App) steamRatingResource(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.PathValue("id") == "batch":
+		req.SetPathValue("id", req.PathValue("resource"))
+		app.getSteamRatingBatch(w, req)
+	case req.PathValue("resource") == "report":
+		app.getSteamRatingReport(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (app *App) getSteamRatingReport(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = steamrating.ReportMarkdown
+	}
+
+	record, err := app.historySvc.Get(req.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		apiResp.ErrorMessage = "Error loading the rating"
+		if errors.Is(err, steamrating.ErrRatingNotFound) {
+			status = http.StatusNotFound
+			apiResp.ErrorMessage = "Rating not found"
+		} else {
+			app.logger.ErrorContext(req.Context(), "loading rating", "err", err)
+		}
+		err := app.encodeJsonResponse(w, apiResp, status)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	report, contentType, err := app.reportSvc.Render(req.Context(), record, format)
+	if err != nil {
+		status := http.StatusInternalServerError
+		apiResp.ErrorMessage = "Error rendering the report"
+		if errors.Is(err, steamrating.ErrUnknownReportFormat) {
+			status = http.StatusBadRequest
+			apiResp.ErrorMessage = err.Error()
+		} else {
+			app.logger.ErrorContext(req.Context(), "rendering report", "format", format, "err", err)
+		}
+		err := app.encodeJsonResponse(w, apiResp, status)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if format != steamrating.ReportHTML {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"steam-rating-%s.%s\"", record.ID, format))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(report); err != nil {
+		app.logger.ErrorContext(req.Context(), "writing report", "err", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"gdrsapi/internal/gamedocgen"
+)
+
+func (app *App) listTemplates(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is GET
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	apiResp.Result = app.documentSvc.Templates().List()
+	apiResp.Sucess = true
+	err := app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) getTemplate(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is GET
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	tmpl, err := app.documentSvc.Templates().Get(req.PathValue("name"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gamedocgen.ErrUnknownTemplate) {
+			status = http.StatusNotFound
+		}
+		apiResp.ErrorMessage = err.Error()
+		err := app.encodeJsonResponse(w, apiResp, status)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	apiResp.Result = tmpl
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
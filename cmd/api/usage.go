@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"gdrsapi/internal/usage"
+	"gdrsapi/pkg/clientip"
+)
+
+// metered records the model calls a handler makes against its route and
+// client, the api key or else the ip. New work is rejected once the daily
+// spend limit is reached, reads such as a chat history still go through.
+func (app *App) metered(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			if err := app.usageSvc.CheckBudget(); err != nil {
+				status, message := http.StatusInternalServerError, "Error checking the daily usage"
+				if errors.Is(err, usage.ErrBudgetExceeded) {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(app.usageSvc.ResetIn().Seconds()))))
+					status, message = llmErrorResponse(err, http.StatusServiceUnavailable)
+				} else {
+					app.logger.ErrorContext(req.Context(), "checking daily usage", "err", err)
+				}
+				err := app.encodeJsonResponse(w, &ApiResponse{ErrorMessage: message}, status)
+				if err != nil {
+					app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+				}
+				return
+			}
+		}
+
+		ctx := usage.WithTracker(req.Context(), app.usageSvc)
+		ctx = usage.WithAttribution(ctx, usage.Attribution{Endpoint: req.Pattern})
+		if usage.AttributionFrom(ctx).Client == "" {
+			ctx = usage.WithAttribution(ctx, usage.Attribution{Client: clientip.FromRequest(req)})
+		}
+		next(w, req.WithContext(ctx))
+	}
+}
+
+// backgroundContext is the context of work the server starts on its own,
+// such as scheduled watchlist checks.
+func (app *App) backgroundContext(name string) context.Context {
+	ctx := usage.WithTracker(context.Background(), app.usageSvc)
+	return usage.WithAttribution(ctx, usage.Attribution{Endpoint: name, Client: "server"})
+}
+
+// usageResponse is the usage of a day against the daily spend limit.
+type usageResponse struct {
+	*usage.Day
+	DailyLimit float64  `json:"dailyLimit"`
+	Remaining  *float64 `json:"remaining,omitempty"`
+}
+
+func (app *App) getUsage(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// check if method is GET
+	if req.Method != http.MethodGet {
+		apiResp.ErrorMessage = "Only GET method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	date := req.URL.Query().Get("date")
+	if date == "" {
+		date = app.usageSvc.Today()
+	}
+
+	day, err := app.usageSvc.Day(date)
+	if err != nil {
+		apiResp.ErrorMessage = err.Error()
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	resp := usageResponse{Day: day, DailyLimit: app.usageSvc.DailyLimit()}
+	if resp.DailyLimit > 0 && date == app.usageSvc.Today() {
+		remaining := max(resp.DailyLimit-day.Total.Cost, 0)
+		resp.Remaining = &remaining
+	}
+
+	apiResp.Result = resp
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
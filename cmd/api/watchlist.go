@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"gdrsapi/internal/steamrating"
+	"gdrsapi/internal/watchlist"
+)
+
+func (app *App) watchlistHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		app.listWatchlist(w, req)
+	case http.MethodPost:
+		app.addToWatchlist(w, req)
+	default:
+		apiResp := &ApiResponse{ErrorMessage: "Only GET and POST methods are allowed"}
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+	}
+}
+
+func (app *App) listWatchlist(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	entries, err := app.watchlistSvc.List(requestClient(req))
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "loading watchlist", "err", err)
+		apiResp.ErrorMessage = "Error loading the watchlist"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusInternalServerError)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	apiResp.Result = entries
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) addToWatchlist(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	// now check if we can process the request body
+	if err := req.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		apiResp.ErrorMessage = "Form body is too large"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	// the app can be sent as a store url or an app id
+	target := req.PostFormValue("url")
+	if target == "" {
+		target = req.PostFormValue("appId")
+	}
+
+	appId, err := steamrating.ParseSteamAppId(target)
+	if err != nil {
+		apiResp.ErrorMessage = "A valid steam url or app id is required"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	schedule := req.PostFormValue("schedule")
+	if schedule == "" {
+		schedule = watchlist.ScheduleDaily
+	}
+
+	entry, err := app.watchlistSvc.Add(requestClient(req), appId, schedule)
+	if err != nil {
+		apiResp.ErrorMessage = err.Error()
+		err := app.encodeJsonResponse(w, apiResp, http.StatusBadRequest)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	apiResp.Result = entry
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
+
+func (app *App) removeFromWatchlist(w http.ResponseWriter, req *http.Request) {
+	apiResp := &ApiResponse{
+		Result:       nil,
+		Sucess:       false,
+		ErrorMessage: "",
+	}
+
+	if req.Method != http.MethodDelete {
+		apiResp.ErrorMessage = "Only DELETE method is allowed"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusMethodNotAllowed)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	// anonymous entries belong to an ip that many clients can share behind
+	// a nat, so only entries added with a key can be removed
+	key, ok := requestKey(req)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gdrsapi"`)
+		apiResp.ErrorMessage = "An api key is required to remove apps from the watchlist"
+		err := app.encodeJsonResponse(w, apiResp, http.StatusUnauthorized)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	err := app.watchlistSvc.Remove(keyClient(key.ID), req.PathValue("appId"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		apiResp.ErrorMessage = "Error removing app from the watchlist"
+		if errors.Is(err, watchlist.ErrNotWatched) {
+			status = http.StatusNotFound
+			apiResp.ErrorMessage = err.Error()
+		} else {
+			app.logger.ErrorContext(req.Context(), "removing app from the watchlist", "err", err)
+		}
+		err := app.encodeJsonResponse(w, apiResp, status)
+		if err != nil {
+			app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+		}
+		return
+	}
+
+	apiResp.Sucess = true
+	err = app.encodeJsonResponse(w, apiResp, http.StatusOK)
+	if err != nil {
+		app.logger.ErrorContext(req.Context(), "writing json response", "err", err)
+	}
+}
@@ -0,0 +1,63 @@
+// webhookrecv is a local receiver for testing outbound webhooks. It verifies
+// signatures and prints every event it gets.
+//
+//	go run ./cmd/webhookrecv -addr :8090 -secret $WEBHOOK_SECRET
+//
+// Point WEBHOOK_URLS at http://localhost:8090/ to use it. The -fail flag makes
+// it answer with a 500 so retries and the dead letter file can be tested.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"gdrsapi/internal/webhooks"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "address to listen on")
+	secret := flag.String("secret", os.Getenv("WEBHOOK_SECRET"), "secret used to verify signatures, skipped when empty")
+	fail := flag.Bool("fail", false, "reject every delivery with a 500")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		event := r.Header.Get(webhooks.EventHeader)
+		delivery := r.Header.Get(webhooks.DeliveryHeader)
+
+		if *secret != "" {
+			err := webhooks.Verify(*secret, r.Header.Get(webhooks.TimestampHeader), body, r.Header.Get(webhooks.SignatureHeader))
+			if err != nil {
+				log.Printf("rejected %s %s: %s", event, delivery, err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if *fail {
+			log.Printf("failing %s %s on purpose", event, delivery)
+			http.Error(w, "failing on purpose", http.StatusInternalServerError)
+			return
+		}
+
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, body, "", "  "); err != nil {
+			pretty.Write(body)
+		}
+		log.Printf("received %s %s\n%s", event, delivery, pretty.String())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Listening for webhooks on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
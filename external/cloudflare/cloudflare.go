@@ -2,15 +2,27 @@ package cloudflare
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"gdrsapi/pkg/config"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/metering"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
 
-const baseUrl string = "https://api.cloudflare.com/client/v4/accounts/"
+const (
+	baseUrl        string = "https://api.cloudflare.com/client/v4/accounts/"
+	imgToTextModel string = "@cf/llava-hf/llava-1.5-7b-hf"
+)
+
+// ErrRateLimited is returned when Cloudflare answers with 429.
+var ErrRateLimited = errors.New("too many requests sent. Rate limited by Cloudflare")
 
 type CFService struct {
 	httpClient *http.Client
@@ -33,39 +45,62 @@ func NewCFService() *CFService {
 	}
 }
 
-// This will return the response as bytes
-func (cf *CFService) CallImgToTextApi(payload []byte) ([]byte, error) {
+// This will return the response as bytes. The usage of the call goes to the
+// metering recorder carried by ctx.
+func (cf *CFService) CallImgToTextApi(ctx context.Context, payload []byte) ([]byte, error) {
 	var bearer string = "Bearer " + cf.cfg.CloudflareApiKey
-	var url string = fmt.Sprintf("%s%s/ai/run/@cf/llava-hf/llava-1.5-7b-hf", baseUrl, cf.cfg.CloudflareAccountId)
+	var url string = fmt.Sprintf("%s%s/ai/run/%s", baseUrl, cf.cfg.CloudflareAccountId, imgToTextModel)
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
-	req.Header.Set("Authorization", bearer)
-	req.Header.Set("Content-Type", "application/json")
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
+	req.Header.Set("Authorization", bearer)
+	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := cf.httpClient.Do(req)
 	if err != nil {
-		log.Printf("Failed to call img to text api: %w", err)
-		return nil, err
+		slog.ErrorContext(ctx, "calling img to text api", "err", err)
+		return nil, fmt.Errorf("calling img to text api: %w", err)
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("reading img to text response: %w", err)
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		log.Printf("Response Status: %d", resp.StatusCode)
-		log.Printf("Response Body: %s", string(bodyBytes))
-		return nil, fmt.Errorf("too many requests sent. Rate limited by Cloudflare")
+		slog.WarnContext(ctx, "cloudflare rate limited", "status", resp.StatusCode, logger.Sensitive("body", string(bodyBytes)))
+		return nil, ErrRateLimited
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(bodyBytes))
 	}
 
+	metering.Record(ctx, callUsage(bodyBytes))
 	return bodyBytes, nil
 }
+
+// callUsage reads the token usage some models report. Neurons are not part
+// of the response, the usage tracker estimates them.
+func callUsage(body []byte) metering.Call {
+	var resp struct {
+		Result struct {
+			Usage struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+		} `json:"result"`
+	}
+	// a response without usage still counts as a call
+	_ = json.Unmarshal(body, &resp)
+
+	return metering.Call{
+		Provider:     metering.ProviderCloudflare,
+		Model:        imgToTextModel,
+		InputTokens:  resp.Result.Usage.PromptTokens,
+		OutputTokens: resp.Result.Usage.CompletionTokens,
+	}
+}
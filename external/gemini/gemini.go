@@ -2,17 +2,22 @@ package gemini
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"gdrsapi/pkg/config"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/metering"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
 
 const (
-	GEMINI_API_URL = "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.0-flash:generateContent?key="
+	GEMINI_MODEL   = "gemini-2.0-flash"
+	GEMINI_API_URL = "https://generativelanguage.googleapis.com/v1beta/models/" + GEMINI_MODEL + ":generateContent"
 )
 
 type GeminiService struct {
@@ -38,78 +43,104 @@ func NewGeminiService(genCfg map[string]interface{}) *GeminiService {
 	}
 }
 
-func (g *GeminiService) CallGeminiLLMApi(propmt string) ([]byte, error) {
-	url := GEMINI_API_URL + g.cfg.GeminiApiKey
-
-	type TextPart struct {
-		Text string `json:"text"`
-	}
+// CallGeminiLLMApi sends a single user prompt and returns the text of the
+// answer.
+func (g *GeminiService) CallGeminiLLMApi(ctx context.Context, propmt string) ([]byte, error) {
+	return g.Send(ctx, NewRequest().User(propmt))
+}
 
-	type Content struct {
-		Parts []TextPart `json:"parts"`
+// Send sends the request and returns the text of the first candidate.
+func (g *GeminiService) Send(ctx context.Context, req *Request) ([]byte, error) {
+	content, err := g.SendContent(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+	return []byte(content.Text()), nil
+}
 
-	type Candidate struct {
-		Content Content `json:"content"`
+// SendContent sends the request and returns the first candidate, for replies
+// that can hold function calls.
+func (g *GeminiService) SendContent(ctx context.Context, req *Request) (*Content, error) {
+	resp, err := g.Generate(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+	return &resp.Candidates[0].Content, nil
+}
 
-	type GeminiResponse struct {
-		Candidates []Candidate `json:"candidates"`
+// Generate sends the request and returns the full response. Responses that
+// can not be used come back with a *ResponseError, together with the
+// response itself so its token usage is still known. The usage of every call
+// goes to the metering recorder carried by ctx.
+func (g *GeminiService) Generate(ctx context.Context, req *Request) (*Response, error) {
+	// the call's overrides go on top of the service's generation config
+	inputData := *req
+	inputData.GenerationConfig = make(map[string]interface{}, len(g.genConfig)+len(req.GenerationConfig))
+	for k, v := range g.genConfig {
+		inputData.GenerationConfig[k] = v
 	}
-
-	inputData := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"parts": []map[string]interface{}{
-					{
-						"text": propmt,
-					},
-				},
-			},
-		},
-		"generationConfig": g.genConfig,
+	for k, v := range req.GenerationConfig {
+		inputData.GenerationConfig[k] = v
 	}
 
 	jsonInput, err := json.Marshal(inputData)
 	if err != nil {
-		log.Printf("Error marshaling JSON: %v", err)
+		slog.ErrorContext(ctx, "encoding gemini request", "err", err)
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonInput))
-	req.Header.Set("Content-Type", "application/json")
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", GEMINI_API_URL, bytes.NewReader(jsonInput))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	// the key goes in a header, in the url it would end up in errors and logs
+	httpReq.Header.Set("x-goog-api-key", g.cfg.GeminiApiKey)
 
-	log.Println("sent gemini request")
-	resp, err := g.httpClient.Do(req)
+	slog.DebugContext(ctx, "sending gemini request", "model", GEMINI_MODEL, logger.Sensitive("request", string(jsonInput)))
+	resp, err := g.httpClient.Do(httpReq)
 	if err != nil {
-		log.Printf("Gemini LLM: Error sending request: %v", err)
+		slog.ErrorContext(ctx, "sending gemini request", "err", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
-	log.Println("received gemini response")
+	slog.DebugContext(ctx, "received gemini response", "status", resp.StatusCode)
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("reading response body: %w", err)
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		log.Printf("Response Status: %d", resp.StatusCode)
-		log.Printf("Response Body: %s", string(bodyBytes))
-		return nil, fmt.Errorf("too many requests sent. Rate limited by Cloudflare")
+		slog.WarnContext(ctx, "gemini rate limited", "status", resp.StatusCode, logger.Sensitive("body", string(bodyBytes)))
+		return nil, fmt.Errorf("%w: too many requests sent", ErrRateLimited)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(bodyBytes))
 	}
 
-	var response GeminiResponse
-	err = json.Unmarshal(bodyBytes, &response)
+	response := &Response{}
+	err = json.Unmarshal(bodyBytes, response)
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal response: %w", err)
 	}
-	responseBody := response.Candidates[0].Content.Parts[0].Text
-	return []byte(responseBody), nil
+
+	tokens := response.UsageMetadata
+	slog.InfoContext(ctx, "gemini usage",
+		"promptTokens", tokens.PromptTokenCount,
+		"candidatesTokens", tokens.CandidatesTokenCount,
+		"totalTokens", tokens.TotalTokenCount,
+	)
+	metering.Record(ctx, metering.Call{
+		Provider:     metering.ProviderGemini,
+		Model:        GEMINI_MODEL,
+		InputTokens:  tokens.PromptTokenCount,
+		OutputTokens: tokens.CandidatesTokenCount,
+	})
+
+	if err := response.Check(); err != nil {
+		slog.WarnContext(ctx, "unusable gemini response", "err", err)
+		return response, err
+	}
+	return response, nil
 }
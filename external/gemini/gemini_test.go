@@ -1,6 +1,9 @@
 package gemini
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestGemini(t *testing.T) {
 	simpleCfg := map[string]interface{}{
@@ -10,7 +13,7 @@ func TestGemini(t *testing.T) {
 
 	g := NewGeminiService(simpleCfg)
 	prompt := "Write a short story about a cat named Fluffy"
-	response, err := g.CallGeminiLLMApi(prompt)
+	response, err := g.CallGeminiLLMApi(context.Background(), prompt)
 
 	t.Log(string(response))
 	t.Log(err)
@@ -0,0 +1,180 @@
+package gemini
+
+import (
+	"gdrsapi/pkg/schema"
+	"strings"
+)
+
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+
+	HarmCategoryHarassment       = "HARM_CATEGORY_HARASSMENT"
+	HarmCategoryHateSpeech       = "HARM_CATEGORY_HATE_SPEECH"
+	HarmCategorySexuallyExplicit = "HARM_CATEGORY_SEXUALLY_EXPLICIT"
+	HarmCategoryDangerousContent = "HARM_CATEGORY_DANGEROUS_CONTENT"
+
+	BlockNone           = "BLOCK_NONE"
+	BlockOnlyHigh       = "BLOCK_ONLY_HIGH"
+	BlockMediumAndAbove = "BLOCK_MEDIUM_AND_ABOVE"
+	BlockLowAndAbove    = "BLOCK_LOW_AND_ABOVE"
+)
+
+// Content is one turn of a conversation.
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+// Part is a piece of a turn. Only one of its fields is set.
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// Blob is inline media such as an image. Data is sent base64 encoded.
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+type FunctionCall struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// FunctionDeclaration describes a function the model may ask to call.
+type FunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  *schema.Schema `json:"parameters,omitempty"`
+}
+
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// TextContent returns a turn holding a single text part.
+func TextContent(role string, text string) Content {
+	return Content{Role: role, Parts: []Part{{Text: text}}}
+}
+
+// Text joins the text parts of the content.
+func (c Content) Text() string {
+	var sb strings.Builder
+	for _, p := range c.Parts {
+		sb.WriteString(p.Text)
+	}
+	return sb.String()
+}
+
+// FunctionCalls returns the calls the model asked for.
+func (c Content) FunctionCalls() []FunctionCall {
+	var calls []FunctionCall
+	for _, p := range c.Parts {
+		if p.FunctionCall != nil {
+			calls = append(calls, *p.FunctionCall)
+		}
+	}
+	return calls
+}
+
+// Request is a generateContent request. It is built with chained calls:
+//
+//	req := gemini.NewRequest().
+//		System("You are a game design expert.").
+//		User(prompt).
+//		Schema(responseSchema)
+//
+// GenerationConfig only holds the overrides of this call, they are applied
+// on top of the service's generation config when the request is sent.
+type Request struct {
+	SystemInstruction *Content               `json:"systemInstruction,omitempty"`
+	Contents          []Content              `json:"contents"`
+	Tools             []Tool                 `json:"tools,omitempty"`
+	SafetySettings    []SafetySetting        `json:"safetySettings,omitempty"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+}
+
+func NewRequest() *Request {
+	return &Request{Contents: []Content{}}
+}
+
+// System sets the system instruction, where personas and standing rules go.
+func (r *Request) System(text string) *Request {
+	c := TextContent("", text)
+	r.SystemInstruction = &c
+	return r
+}
+
+// User adds a user turn.
+func (r *Request) User(text string) *Request {
+	return r.Add(TextContent(RoleUser, text))
+}
+
+// Model adds a model turn, for replaying earlier answers.
+func (r *Request) Model(text string) *Request {
+	return r.Add(TextContent(RoleModel, text))
+}
+
+// Add appends turns as they are.
+func (r *Request) Add(contents ...Content) *Request {
+	r.Contents = append(r.Contents, contents...)
+	return r
+}
+
+// Image attaches inline image data to the last user turn, or to a new user
+// turn when the conversation does not end with one.
+func (r *Request) Image(mimeType string, data []byte) *Request {
+	part := Part{InlineData: &Blob{MimeType: mimeType, Data: data}}
+	if n := len(r.Contents); n > 0 && r.Contents[n-1].Role == RoleUser {
+		r.Contents[n-1].Parts = append(r.Contents[n-1].Parts, part)
+		return r
+	}
+	return r.Add(Content{Role: RoleUser, Parts: []Part{part}})
+}
+
+// Safety sets the blocking threshold of a harm category.
+func (r *Request) Safety(category string, threshold string) *Request {
+	for i, s := range r.SafetySettings {
+		if s.Category == category {
+			r.SafetySettings[i].Threshold = threshold
+			return r
+		}
+	}
+	r.SafetySettings = append(r.SafetySettings, SafetySetting{Category: category, Threshold: threshold})
+	return r
+}
+
+// Config overrides a generation config option, such as temperature, for
+// this call only.
+func (r *Request) Config(key string, value interface{}) *Request {
+	if r.GenerationConfig == nil {
+		r.GenerationConfig = make(map[string]interface{})
+	}
+	r.GenerationConfig[key] = value
+	return r
+}
+
+// Schema constrains the reply to json matching the schema.
+func (r *Request) Schema(responseSchema *schema.Schema) *Request {
+	return r.Config("response_mime_type", "application/json").Config("response_schema", responseSchema)
+}
+
+// Functions declares functions the model may call instead of answering.
+func (r *Request) Functions(declarations ...FunctionDeclaration) *Request {
+	r.Tools = append(r.Tools, Tool{FunctionDeclarations: declarations})
+	return r
+}
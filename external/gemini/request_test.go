@@ -0,0 +1,95 @@
+package gemini
+
+import (
+	"encoding/json"
+	"gdrsapi/pkg/schema"
+	"strings"
+	"testing"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	req := NewRequest().
+		System("You are a game design expert.").
+		User("Describe the game").
+		Model(`{"overview": "Dreams"}`).
+		User("Now the art style").
+		Image("image/png", []byte{1, 2, 3}).
+		Safety(HarmCategoryDangerousContent, BlockOnlyHigh).
+		Safety(HarmCategoryDangerousContent, BlockNone).
+		Config("temperature", 0.2).
+		Schema(schema.Object().Property("overview", schema.String())).
+		Functions(FunctionDeclaration{Name: "lookup_game", Description: "Finds a game on Steam"})
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		SystemInstruction struct {
+			Role  string `json:"role"`
+			Parts []Part `json:"parts"`
+		} `json:"systemInstruction"`
+		Contents         []Content              `json:"contents"`
+		Tools            []Tool                 `json:"tools"`
+		SafetySettings   []SafetySetting        `json:"safetySettings"`
+		GenerationConfig map[string]interface{} `json:"generationConfig"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.SystemInstruction.Role != "" || got.SystemInstruction.Parts[0].Text != "You are a game design expert." {
+		t.Errorf("unexpected system instruction %+v", got.SystemInstruction)
+	}
+
+	roles := make([]string, len(got.Contents))
+	for i, c := range got.Contents {
+		roles[i] = c.Role
+	}
+	if strings.Join(roles, ",") != "user,model,user" {
+		t.Errorf("unexpected roles %v", roles)
+	}
+
+	last := got.Contents[2]
+	if len(last.Parts) != 2 || last.Parts[1].InlineData == nil || string(last.Parts[1].InlineData.Data) != "\x01\x02\x03" {
+		t.Errorf("the image should be attached to the last user turn: %+v", last)
+	}
+	if !strings.Contains(string(body), `"data":"AQID"`) {
+		t.Errorf("image data should be base64 encoded: %s", body)
+	}
+
+	if len(got.SafetySettings) != 1 || got.SafetySettings[0].Threshold != BlockNone {
+		t.Errorf("the last threshold of a category should win: %+v", got.SafetySettings)
+	}
+
+	if got.GenerationConfig["temperature"] != 0.2 || got.GenerationConfig["response_mime_type"] != "application/json" || got.GenerationConfig["response_schema"] == nil {
+		t.Errorf("unexpected generation config %+v", got.GenerationConfig)
+	}
+
+	if len(got.Tools) != 1 || got.Tools[0].FunctionDeclarations[0].Name != "lookup_game" {
+		t.Errorf("unexpected tools %+v", got.Tools)
+	}
+}
+
+func TestRequestImageStartsUserTurn(t *testing.T) {
+	req := NewRequest().User("What is in this capsule?").Model("A castle").Image("image/jpeg", []byte("jpg"))
+
+	if len(req.Contents) != 3 || req.Contents[2].Role != RoleUser || req.Contents[2].Parts[0].InlineData == nil {
+		t.Errorf("expected a new user turn for the image, got %+v", req.Contents)
+	}
+}
+
+func TestContentFunctionCalls(t *testing.T) {
+	c := Content{Role: RoleModel, Parts: []Part{
+		{Text: "Let me look that up. "},
+		{FunctionCall: &FunctionCall{Name: "lookup_game", Args: map[string]interface{}{"name": "Celeste"}}},
+	}}
+
+	if c.Text() != "Let me look that up. " {
+		t.Errorf("unexpected text %q", c.Text())
+	}
+	if calls := c.FunctionCalls(); len(calls) != 1 || calls[0].Args["name"] != "Celeste" {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+}
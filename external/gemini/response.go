@@ -0,0 +1,143 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	FinishStop              = "STOP"
+	FinishMaxTokens         = "MAX_TOKENS"
+	FinishSafety            = "SAFETY"
+	FinishRecitation        = "RECITATION"
+	FinishBlocklist         = "BLOCKLIST"
+	FinishProhibitedContent = "PROHIBITED_CONTENT"
+	FinishSPII              = "SPII"
+)
+
+var (
+	// ErrBlocked means the prompt or the answer was stopped by a safety or
+	// content filter.
+	ErrBlocked = errors.New("gemini blocked the response")
+	// ErrTruncated means the answer hit the output token limit and is
+	// incomplete.
+	ErrTruncated = errors.New("gemini response was truncated")
+	// ErrEmptyResponse means gemini answered without any content.
+	ErrEmptyResponse = errors.New("gemini returned an empty response")
+	ErrRateLimited   = errors.New("gemini rate limit reached")
+)
+
+// Response is a generateContent response.
+type Response struct {
+	Candidates     []Candidate     `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  UsageMetadata   `json:"usageMetadata"`
+}
+
+type Candidate struct {
+	Content       Content        `json:"content"`
+	FinishReason  string         `json:"finishReason,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// PromptFeedback is set when the prompt itself was rated or blocked.
+type PromptFeedback struct {
+	BlockReason   string         `json:"blockReason,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// UsageMetadata holds the token counts of a call.
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// ResponseError is returned for responses that can not be used. It wraps
+// ErrBlocked, ErrTruncated or ErrEmptyResponse and keeps the details of the
+// response for logging.
+type ResponseError struct {
+	Err           error
+	Reason        string
+	SafetyRatings []SafetyRating
+	Usage         UsageMetadata
+}
+
+func (e *ResponseError) Error() string {
+	msg := e.Err.Error()
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	if categories := e.BlockedCategories(); len(categories) > 0 {
+		msg += " (" + strings.Join(categories, ", ") + ")"
+	}
+	return msg
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// BlockedCategories lists the harm categories that caused a block.
+func (e *ResponseError) BlockedCategories() []string {
+	var categories []string
+	for _, r := range e.SafetyRatings {
+		if r.Blocked || r.Probability == "HIGH" {
+			categories = append(categories, r.Category)
+		}
+	}
+	return categories
+}
+
+// Text returns the text of the first candidate.
+func (r *Response) Text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	return r.Candidates[0].Content.Text()
+}
+
+// Check returns a *ResponseError when the prompt was blocked or the first
+// candidate is missing, blocked, truncated or empty.
+func (r *Response) Check() error {
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return &ResponseError{
+			Err:           ErrBlocked,
+			Reason:        "prompt blocked for " + r.PromptFeedback.BlockReason,
+			SafetyRatings: r.PromptFeedback.SafetyRatings,
+			Usage:         r.UsageMetadata,
+		}
+	}
+	if len(r.Candidates) == 0 {
+		return &ResponseError{Err: ErrEmptyResponse, Reason: "no candidates", Usage: r.UsageMetadata}
+	}
+
+	c := r.Candidates[0]
+	switch c.FinishReason {
+	case FinishSafety, FinishRecitation, FinishBlocklist, FinishProhibitedContent, FinishSPII:
+		return &ResponseError{
+			Err:           ErrBlocked,
+			Reason:        fmt.Sprintf("answer stopped for %s", c.FinishReason),
+			SafetyRatings: c.SafetyRatings,
+			Usage:         r.UsageMetadata,
+		}
+	case FinishMaxTokens:
+		return &ResponseError{
+			Err:    ErrTruncated,
+			Reason: fmt.Sprintf("output limit reached after %d tokens", r.UsageMetadata.CandidatesTokenCount),
+			Usage:  r.UsageMetadata,
+		}
+	}
+
+	if strings.TrimSpace(c.Content.Text()) == "" && len(c.Content.FunctionCalls()) == 0 {
+		return &ResponseError{Err: ErrEmptyResponse, Reason: c.FinishReason, Usage: r.UsageMetadata}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResponseCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{
+			name: "ok",
+			body: `{"candidates": [{"content": {"role": "model", "parts": [{"text": "{\"overview\": \"Dreams\"}"}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 5, "totalTokenCount": 17}}`,
+		},
+		{
+			name: "function call",
+			body: `{"candidates": [{"content": {"role": "model", "parts": [{"functionCall": {"name": "lookup_game"}}]}, "finishReason": "STOP"}]}`,
+		},
+		{
+			name: "prompt blocked",
+			body: `{"promptFeedback": {"blockReason": "SAFETY", "safetyRatings": [{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true}]}, "usageMetadata": {"promptTokenCount": 12, "totalTokenCount": 12}}`,
+			want: ErrBlocked,
+		},
+		{
+			name: "answer blocked",
+			body: `{"candidates": [{"finishReason": "SAFETY", "safetyRatings": [{"category": "HARM_CATEGORY_HARASSMENT", "probability": "HIGH"}]}]}`,
+			want: ErrBlocked,
+		},
+		{
+			name: "truncated",
+			body: `{"candidates": [{"content": {"role": "model", "parts": [{"text": "{\"overview\": \"Dre"}]}, "finishReason": "MAX_TOKENS"}], "usageMetadata": {"candidatesTokenCount": 8192}}`,
+			want: ErrTruncated,
+		},
+		{
+			name: "no candidates",
+			body: `{}`,
+			want: ErrEmptyResponse,
+		},
+		{
+			name: "empty text",
+			body: `{"candidates": [{"content": {"role": "model", "parts": [{"text": "  "}]}, "finishReason": "STOP"}]}`,
+			want: ErrEmptyResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp Response
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			err := resp.Check()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			var respErr *ResponseError
+			if !errors.As(err, &respErr) {
+				t.Fatalf("expected a *ResponseError, got %T", err)
+			}
+			if respErr.Usage != resp.UsageMetadata {
+				t.Errorf("the error should carry the usage, got %+v", respErr.Usage)
+			}
+		})
+	}
+}
+
+func TestResponseErrorMessage(t *testing.T) {
+	resp := Response{PromptFeedback: &PromptFeedback{
+		BlockReason: "SAFETY",
+		SafetyRatings: []SafetyRating{
+			{Category: HarmCategoryDangerousContent, Probability: "HIGH", Blocked: true},
+			{Category: HarmCategoryHarassment, Probability: "NEGLIGIBLE"},
+		},
+	}}
+
+	msg := resp.Check().Error()
+	if !strings.Contains(msg, "prompt blocked for SAFETY") || !strings.Contains(msg, HarmCategoryDangerousContent) || strings.Contains(msg, HarmCategoryHarassment) {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
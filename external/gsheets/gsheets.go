@@ -4,9 +4,9 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"gdrsapi/pkg/config"
 	"log"
+	"log/slog"
 	"os"
 
 	"google.golang.org/api/option"
@@ -32,7 +32,7 @@ func NewSheetsService() *SheetsApp {
 		log.Fatalf("Invalid JSON in decoded credentials: %v", err)
 	}
 
-	fmt.Println("loaded google service account credentials")
+	slog.Info("loaded google service account credentials")
 
 	sheetsService, err := sheets.NewService(ctx,
 		option.WithCredentialsJSON(credBytes),
@@ -42,13 +42,13 @@ func NewSheetsService() *SheetsApp {
 		log.Fatalf("Unable to create sheets service: %v", err)
 	}
 
-	fmt.Println("Sheets service created")
+	slog.Info("sheets service created")
 	return &SheetsApp{
 		sheetSvc: sheetsService,
 	}
 }
 
-func (sApp *SheetsApp) InsertSteamRatingEntry(se SheetsEntry) error {
+func (sApp *SheetsApp) InsertSteamRatingEntry(ctx context.Context, se SheetsEntry) error {
 	sheetsId := "1SHupRSsjmSuDFuAiYtrfHlpg0n0LgLKoXys1QVXGkdo"
 	sheetsRange := "Sheet1!A:G"
 
@@ -56,7 +56,7 @@ func (sApp *SheetsApp) InsertSteamRatingEntry(se SheetsEntry) error {
 	objectList := []interface{}{se.Title, se.AppId, se.Url, se.PromptType, se.Score, se.Rating, se.Prompt}
 	vr.Values = [][]interface{}{objectList}
 
-	err := sApp.InsertSheetsRow(sheetsId, sheetsRange, vr)
+	err := sApp.InsertSheetsRow(ctx, sheetsId, sheetsRange, vr)
 	if err != nil {
 		return err
 	}
@@ -64,8 +64,8 @@ func (sApp *SheetsApp) InsertSteamRatingEntry(se SheetsEntry) error {
 	return nil
 }
 
-func (sApp *SheetsApp) InsertSheetsRow(sheetId string, sheetsRange string, vr *sheets.ValueRange) error {
-	_, err := sApp.sheetSvc.Spreadsheets.Values.Append(sheetId, sheetsRange, vr).ValueInputOption("USER_ENTERED").Do()
+func (sApp *SheetsApp) InsertSheetsRow(ctx context.Context, sheetId string, sheetsRange string, vr *sheets.ValueRange) error {
+	_, err := sApp.sheetSvc.Spreadsheets.Values.Append(sheetId, sheetsRange, vr).ValueInputOption("USER_ENTERED").Context(ctx).Do()
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package gsheets
 
 import (
+	"context"
 	"testing"
 )
 
@@ -19,7 +20,7 @@ func TestInitSheetsApp(t *testing.T) {
 		Prompt:     "test",
 	}
 
-	err := svc.InsertSteamRatingEntry(se)
+	err := svc.InsertSteamRatingEntry(context.Background(), se)
 	if err != nil {
 		t.Errorf("Error inserting entry: %v", err)
 	}
@@ -0,0 +1,281 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gdrsapi/pkg/store"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	keysCollection  = "apikeys"
+	usageCollection = "apikey_usage"
+
+	tokenPrefix = "gdrs_"
+
+	// AnyEndpoint is the quota key that applies to endpoints without a quota
+	// of their own
+	AnyEndpoint = "*"
+)
+
+var (
+	ErrKeyNotFound   = errors.New("api key not found")
+	ErrInvalidKey    = errors.New("invalid api key")
+	ErrKeyRevoked    = errors.New("api key has been revoked")
+	ErrRateLimited   = errors.New("api key rate limit exceeded")
+	ErrQuotaExceeded = errors.New("api key daily quota exceeded")
+)
+
+// Key is an api key without its secret. RateLimit is in tokens per minute
+// and Quotas maps route patterns to tokens per day. A request spends one
+// token per model call it makes and one otherwise.
+type Key struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	RateLimit int            `json:"rateLimit"`
+	Quotas    map[string]int `json:"quotas,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	RevokedAt *time.Time     `json:"revokedAt,omitempty"`
+}
+
+// storedKey is a key as it is kept on disk. Only the hash of the token is
+// kept, so a leaked data dir does not leak working keys.
+type storedKey struct {
+	Key
+	TokenHash string `json:"tokenHash"`
+}
+
+// Usage counts the tokens a key spent per endpoint on one UTC day.
+type Usage struct {
+	Date     string         `json:"date"`
+	Requests map[string]int `json:"requests"`
+}
+
+// KeyStore issues, checks and counts api keys.
+type KeyStore struct {
+	mu       sync.Mutex
+	store    *store.Store
+	limiters map[string]*rate.Limiter
+	now      func() time.Time
+}
+
+func NewKeyStore(store *store.Store) *KeyStore {
+	return &KeyStore{
+		store:    store,
+		limiters: make(map[string]*rate.Limiter),
+		now:      time.Now,
+	}
+}
+
+// Issue creates a key and returns it with its token. The token is only
+// known at this point.
+func (k *KeyStore) Issue(name string, rateLimit int, quotas map[string]int) (*Key, string, error) {
+	if name == "" {
+		return nil, "", errors.New("a key name is required")
+	}
+	if rateLimit < 1 {
+		return nil, "", errors.New("rate limit must be a positive number")
+	}
+	for endpoint, quota := range quotas {
+		if quota < 1 {
+			return nil, "", fmt.Errorf("quota for %s must be a positive number", endpoint)
+		}
+	}
+
+	id := randomHex(8)
+	token := tokenPrefix + id + "_" + randomHex(24)
+	key := storedKey{
+		Key: Key{
+			ID:        id,
+			Name:      name,
+			RateLimit: rateLimit,
+			Quotas:    quotas,
+			CreatedAt: k.now().UTC(),
+		},
+		TokenHash: hashToken(token),
+	}
+
+	if err := k.store.Put(keysCollection, id, key); err != nil {
+		return nil, "", fmt.Errorf("saving api key: %w", err)
+	}
+	return &key.Key, token, nil
+}
+
+func (k *KeyStore) get(id string) (*storedKey, error) {
+	var key storedKey
+	err := k.store.Get(keysCollection, id, &key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (k *KeyStore) Get(id string) (*Key, error) {
+	key, err := k.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &key.Key, nil
+}
+
+// List returns every key, revoked ones included, oldest first.
+func (k *KeyStore) List() ([]Key, error) {
+	ids, err := k.store.Keys(keysCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(ids))
+	for _, id := range ids {
+		key, err := k.get(id)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.Key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// Revoke stops the key from authenticating. Its usage is kept.
+func (k *KeyStore) Revoke(id string) (*Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, err := k.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := k.now().UTC()
+		key.RevokedAt = &now
+		if err := k.store.Put(keysCollection, id, key); err != nil {
+			return nil, fmt.Errorf("saving api key: %w", err)
+		}
+	}
+	delete(k.limiters, id)
+	return &key.Key, nil
+}
+
+// Authenticate returns the key a token belongs to.
+func (k *KeyStore) Authenticate(token string) (*Key, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), "_")
+	if !ok || !strings.HasPrefix(token, tokenPrefix) {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := k.get(id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(key.TokenHash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrKeyRevoked
+	}
+	return &key.Key, nil
+}
+
+// Allow spends cost tokens of the key's rate limit and of its daily quota
+// for the endpoint when both can cover them. Requests that cost nothing are
+// not counted.
+func (k *KeyStore) Allow(key *Key, endpoint string, cost int) error {
+	if cost <= 0 {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	l, ok := k.limiters[key.ID]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(float64(key.RateLimit)/60), key.RateLimit)
+		k.limiters[key.ID] = l
+	}
+
+	usage, err := k.usage(key.ID, k.Today())
+	if err != nil {
+		return err
+	}
+	if quota := key.quota(endpoint); quota > 0 && usage.Requests[endpoint]+cost > quota {
+		return fmt.Errorf("%w: this request costs %d of the %d tokens a day for %s", ErrQuotaExceeded, cost, quota, endpoint)
+	}
+	if !l.AllowN(k.now(), cost) {
+		return fmt.Errorf("%w: this request costs %d of the %d tokens a minute", ErrRateLimited, cost, key.RateLimit)
+	}
+
+	usage.Requests[endpoint] += cost
+	if err := k.store.Put(usageCollection, usageKey(key.ID, usage.Date), usage); err != nil {
+		return fmt.Errorf("saving api key usage: %w", err)
+	}
+	return nil
+}
+
+func (key *Key) quota(endpoint string) int {
+	if quota, ok := key.Quotas[endpoint]; ok {
+		return quota
+	}
+	return key.Quotas[AnyEndpoint]
+}
+
+// Usage returns the requests the key made on a date formatted as
+// 2006-01-02.
+func (k *KeyStore) Usage(id string, date string) (*Usage, error) {
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return nil, fmt.Errorf("invalid date %q", date)
+	}
+	if _, err := k.get(id); err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.usage(id, date)
+}
+
+func (k *KeyStore) usage(id string, date string) (*Usage, error) {
+	usage := &Usage{Date: date, Requests: make(map[string]int)}
+	err := k.store.Get(usageCollection, usageKey(id, date), usage)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// Today returns the date quotas are counted for.
+func (k *KeyStore) Today() string {
+	return k.now().UTC().Format(time.DateOnly)
+}
+
+func usageKey(id string, date string) string {
+	return id + "_" + date
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating api key: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
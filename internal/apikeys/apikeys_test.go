@@ -0,0 +1,178 @@
+package apikeys
+
+import (
+	"errors"
+	"gdrsapi/pkg/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeyStore(s)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+
+	key, token, err := keys.Issue("partner", 60, map[string]int{"/getsteamrating": 2, AnyEndpoint: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, tokenPrefix+key.ID+"_") {
+		t.Errorf("unexpected token %s", token)
+	}
+
+	// only the hash of the token is kept
+	data, err := os.ReadFile(filepath.Join(dir, keysCollection, key.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Errorf("the token was stored in plain text")
+	}
+
+	got, err := keys.Authenticate(token)
+	if err != nil || got.ID != key.ID {
+		t.Fatalf("expected key %s, got %+v %v", key.ID, got, err)
+	}
+	for _, bad := range []string{"", "gdrs_", token + "x", "gdrs_unknown_secret", strings.TrimPrefix(token, tokenPrefix)} {
+		if _, err := keys.Authenticate(bad); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("expected ErrInvalidKey for %q, got %v", bad, err)
+		}
+	}
+
+	// the endpoint quota applies before the catch all one
+	for i := 0; i < 2; i++ {
+		if err := keys.Allow(got, "/getsteamrating", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := keys.Allow(got, "/getsteamrating", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := keys.Allow(got, "/templates", 1); err != nil {
+		t.Errorf("other endpoints should use the catch all quota, got %v", err)
+	}
+
+	usage, err := keys.Usage(key.ID, "2026-10-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Requests["/getsteamrating"] != 2 || usage.Requests["/templates"] != 1 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+
+	// quotas start over the next day
+	now = now.Add(24 * time.Hour)
+	if err := keys.Allow(got, "/getsteamrating", 1); err != nil {
+		t.Errorf("expected a fresh quota, got %v", err)
+	}
+
+	if _, err := keys.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(token); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("expected ErrKeyRevoked, got %v", err)
+	}
+	if _, err := keys.Revoke("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	list, err := keys.List()
+	if err != nil || len(list) != 1 || list[0].RevokedAt == nil {
+		t.Errorf("expected the revoked key in the list, got %+v %v", list, err)
+	}
+}
+
+func TestKeyRateLimit(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeyStore(s)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+
+	key, _, err := keys.Issue("partner", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := keys.Allow(key, "/templates", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := keys.Allow(key, "/templates", 1); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+
+	now = now.Add(30 * time.Second)
+	if err := keys.Allow(key, "/templates", 1); err != nil {
+		t.Errorf("expected a request to be allowed after 30s, got %v", err)
+	}
+
+	if _, _, err := keys.Issue("", 2, nil); err == nil {
+		t.Errorf("expected an error for a key without a name")
+	}
+	if _, _, err := keys.Issue("partner", 2, map[string]int{"/templates": 0}); err == nil {
+		t.Errorf("expected an error for an empty quota")
+	}
+}
+
+func TestAllowCost(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeyStore(s)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+
+	key, _, err := keys.Issue("partner", 12, map[string]int{"/getsteamrating": 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		endpoint string
+		cost     int
+		expected error
+	}{
+		{"first rating", "/getsteamrating", 5, nil},
+		{"free request", "/getsteamrating", 0, nil},
+		{"second rating", "/getsteamrating", 5, nil},
+		{"over the rate limit", "/getsteamrating", 5, ErrRateLimited},
+		{"cheap request within the rate limit", "/templates", 2, nil},
+		{"over the quota", "/getsteamrating", 6, ErrQuotaExceeded},
+	}
+	for _, c := range cases {
+		if err := keys.Allow(key, c.endpoint, c.cost); !errors.Is(err, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, err)
+		}
+	}
+
+	usage, err := keys.Usage(key.ID, "2026-10-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Requests["/getsteamrating"] != 10 || usage.Requests["/templates"] != 2 {
+		t.Errorf("expected only allowed costs to be counted, got %+v", usage.Requests)
+	}
+
+	// a minute later the rate limit is full again and the quota has 5 left
+	now = now.Add(time.Minute)
+	if err := keys.Allow(key, "/getsteamrating", 5); err != nil {
+		t.Errorf("expected the last rating of the quota, got %v", err)
+	}
+	if err := keys.Allow(key, "/getsteamrating", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+}
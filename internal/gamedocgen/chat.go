@@ -0,0 +1,259 @@
+package gamedocgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gdrsapi/external/gemini"
+	"gdrsapi/internal/usage"
+	"gdrsapi/pkg/jsondiff"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/schema"
+	"gdrsapi/pkg/store"
+	"sync"
+	"time"
+)
+
+const (
+	chatsCollection = "designdoc_chats"
+
+	ActionChat = "chat"
+
+	// chatHistoryTurns caps the past turns sent back to the model, older
+	// turns stay in the stored session
+	chatHistoryTurns = 20
+)
+
+var ErrEmptyMessage = errors.New("message is required")
+
+// ChatTurn is one message of a refinement session. Model turns that edited
+// the document point at the version they created.
+type ChatTurn struct {
+	Role      string            `json:"role"`
+	Text      string            `json:"text"`
+	Version   int               `json:"version,omitempty"`
+	Changes   []jsondiff.Change `json:"changes,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// ChatSession is the conversation about one stored document.
+type ChatSession struct {
+	DocumentId string     `json:"documentId"`
+	Turns      []ChatTurn `json:"turns"`
+}
+
+// ChatReply is the model's answer to a message and the version its edits
+// created, if any.
+type ChatReply struct {
+	Reply    string            `json:"reply"`
+	Version  *Version          `json:"version,omitempty"`
+	Changes  []jsondiff.Change `json:"changes"`
+	Document json.RawMessage   `json:"document"`
+}
+
+// ChatService runs multi-turn refinement sessions over stored documents.
+// Edits the model makes are saved as new versions of the document.
+type ChatService struct {
+	gen   *GameDesignDocGen
+	docs  *DocumentStore
+	store *store.Store
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewChatService(gen *GameDesignDocGen, docs *DocumentStore, store *store.Store) *ChatService {
+	return &ChatService{
+		gen:   gen,
+		docs:  docs,
+		store: store,
+		locks: make(map[string]*sync.Mutex),
+	}
+}
+
+// lock serializes the messages of one document so turns stay in order.
+func (c *ChatService) lock(documentId string) func() {
+	c.mu.Lock()
+	l, ok := c.locks[documentId]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[documentId] = l
+	}
+	c.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// Session returns the conversation about the document, empty when none was
+// started.
+func (c *ChatService) Session(documentId string) (*ChatSession, error) {
+	if _, err := c.docs.Get(documentId); err != nil {
+		return nil, err
+	}
+
+	session := &ChatSession{DocumentId: documentId, Turns: []ChatTurn{}}
+	err := c.store.Get(chatsCollection, documentId, session)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Reset forgets the conversation. The document and its versions are kept.
+func (c *ChatService) Reset(documentId string) error {
+	defer c.lock(documentId)()
+
+	if _, err := c.docs.Get(documentId); err != nil {
+		return err
+	}
+	err := c.store.Delete(chatsCollection, documentId)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Send adds the message to the session and asks the model for a reply with
+// the sections it changed. Changed sections are merged into the current
+// version of the document and saved as a new version.
+func (c *ChatService) Send(ctx context.Context, documentId string, message string) (*ChatReply, error) {
+	if message == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	defer c.lock(documentId)()
+
+	session, err := c.Session(documentId)
+	if err != nil {
+		return nil, err
+	}
+	doc, current, err := c.docs.Current(documentId)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := c.gen.templates.Get(doc.Template)
+	if err != nil {
+		return nil, err
+	}
+	ctx = usage.WithAttribution(ctx, usage.Attribution{Template: tmpl.Name})
+
+	replySchema := schema.Object().
+		Property("reply", schema.String().Describe("A short answer to the designer explaining what was changed, or answering their question.")).
+		Property("changes", tmpl.Schema().AllOptional().Describe("Only the sections that were changed, complete with their unchanged parts. Leave it empty when nothing changes."))
+
+	req := gemini.NewRequest().
+		System(designerSystemInstruction + "\n" + GetChatPrompt(tmpl, doc.Title)).
+		Add(chatContents(session.Turns, string(current.Document), message)...).
+		Schema(replySchema)
+	respBytes, err := c.gen.send(ctx, req)
+	if err != nil {
+		c.gen.logger.ErrorContext(ctx, "sending gemini request", "err", err)
+		return nil, err
+	}
+
+	c.gen.logger.DebugContext(ctx, "gemini response", logger.Sensitive("response", string(respBytes)))
+
+	if err := replySchema.Validate(respBytes); err != nil {
+		c.gen.logger.ErrorContext(ctx, "invalid gemini response", "err", err, logger.Sensitive("response", string(respBytes)))
+		return nil, err
+	}
+
+	var modelReply struct {
+		Reply   string          `json:"reply"`
+		Changes json.RawMessage `json:"changes"`
+	}
+	if err := json.Unmarshal(respBytes, &modelReply); err != nil {
+		c.gen.logger.ErrorContext(ctx, "decoding gemini response", "err", err)
+		return nil, err
+	}
+
+	reply := &ChatReply{Reply: modelReply.Reply, Changes: []jsondiff.Change{}, Document: current.Document}
+
+	merged, err := MergeSections(current.Document, modelReply.Changes)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Schema().Validate(merged); err != nil {
+		return nil, fmt.Errorf("%w after the edit: %s", ErrInvalidDocument, err)
+	}
+
+	var before, after interface{}
+	if err := json.Unmarshal(current.Document, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(merged, &after); err != nil {
+		return nil, err
+	}
+
+	modelTurn := ChatTurn{Role: gemini.RoleModel, Text: modelReply.Reply, CreatedAt: time.Now().UTC()}
+	if changes := jsondiff.Diff(before, after); len(changes) > 0 {
+		_, version, err := c.docs.AddVersion(documentId, Version{
+			Action:     ActionChat,
+			Suggestion: message,
+			Document:   merged,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		reply.Version = version
+		reply.Changes = changes
+		reply.Document = merged
+		modelTurn.Version = version.Number
+		modelTurn.Changes = changes
+	}
+
+	session.Turns = append(session.Turns,
+		ChatTurn{Role: gemini.RoleUser, Text: message, CreatedAt: modelTurn.CreatedAt},
+		modelTurn,
+	)
+	if err := c.store.Put(chatsCollection, documentId, session); err != nil {
+		return nil, fmt.Errorf("saving chat session: %w", err)
+	}
+	return reply, nil
+}
+
+// chatContents builds the conversation sent to the model: the recent turns
+// and the new message with the document as it is now.
+func chatContents(turns []ChatTurn, currentDocument string, message string) []gemini.Content {
+	var contents []gemini.Content
+	if len(turns) > chatHistoryTurns {
+		turns = turns[len(turns)-chatHistoryTurns:]
+	}
+	for _, turn := range turns {
+		text := turn.Text
+		if turn.Role == gemini.RoleModel {
+			// model turns are replayed in the shape the model answers in, the
+			// changes are already part of the current document
+			reply, _ := json.Marshal(map[string]interface{}{"reply": turn.Text, "changes": map[string]interface{}{}})
+			text = string(reply)
+		}
+		contents = append(contents, gemini.TextContent(turn.Role, text))
+	}
+
+	return append(contents, gemini.TextContent(gemini.RoleUser, fmt.Sprintf(
+		"This is the current game design document:\n```json\n%s\n```\n\n%s", currentDocument, message,
+	)))
+}
+
+func GetChatPrompt(tmpl *Template, title string) string {
+	return fmt.Sprintf(`
+	You are helping a designer refine the %s game design document of their game %q over a conversation. Follow the instructions below for every message.
+
+	1. The document has the following components:
+		%s
+
+	2. Each message comes with the current document. Do what the designer asks while maintaining consistency with the overall game concept and with earlier requests in the conversation.
+
+	3. Return the sections you changed under "changes", each one complete including its unchanged parts. Leave out the sections you did not change. If the designer only asks a question, answer it and return no changes.
+
+	4. Under "reply", briefly tell the designer what you changed and why, or answer their question.
+
+	5. Remember to be creative, detailed, and consistent with the existing game design.`,
+		tmpl.Title,
+		title,
+		tmpl.OutlineText(),
+	)
+}
@@ -0,0 +1,106 @@
+package gamedocgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gdrsapi/external/gemini"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/store"
+	"strings"
+	"testing"
+)
+
+func TestChatSession(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := NewDocumentStore(s)
+
+	doc, _, err := docs.Create("basic", "Dream Architect", Version{
+		Action:   ActionGenerate,
+		Document: json.RawMessage(`{"overview": "Dreams", "coreGameplay": ["Combat: hack and slash"], "keyFeatures": ["Crafting everything"], "artStyle": ["Pastel"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies := []string{
+		`{"reply": "Combat is now turn based.", "changes": {"coreGameplay": ["Combat: turn based with positioning"]}}`,
+		`{"reply": "It has one main mechanic.", "changes": {}}`,
+	}
+	var sent [][]gemini.Content
+	gen := &GameDesignDocGen{
+		logger:    logger.NewAppLogger(),
+		templates: templates,
+		send: func(ctx context.Context, req *gemini.Request) ([]byte, error) {
+			if !strings.HasPrefix(req.SystemInstruction.Text(), designerSystemInstruction) || !strings.Contains(req.SystemInstruction.Text(), "Dream Architect") {
+				t.Errorf("unexpected system instruction %+v", req.SystemInstruction)
+			}
+			sent = append(sent, req.Contents)
+			reply := replies[0]
+			replies = replies[1:]
+			return []byte(reply), nil
+		},
+	}
+	chat := NewChatService(gen, docs, s)
+
+	reply, err := chat.Send(context.Background(), doc.ID, "make combat more tactical")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Version == nil || reply.Version.Number != 2 || reply.Version.Action != ActionChat {
+		t.Fatalf("expected the edit to create version 2, got %+v", reply.Version)
+	}
+	if len(reply.Changes) != 1 || reply.Changes[0].Path != "coreGameplay[0]" {
+		t.Errorf("unexpected changes %+v", reply.Changes)
+	}
+
+	reply, err = chat.Send(context.Background(), doc.ID, "how many mechanics are there?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Version != nil || len(reply.Changes) != 0 {
+		t.Errorf("a question should not create a version, got %+v", reply)
+	}
+
+	// the second call replays the first exchange and sends the edited document
+	second := sent[1]
+	roles := make([]string, len(second))
+	for i, c := range second {
+		roles[i] = c.Role
+	}
+	if strings.Join(roles, ",") != "user,model,user" {
+		t.Errorf("unexpected roles %v", roles)
+	}
+	if second[0].Text() != "make combat more tactical" || !strings.Contains(second[1].Text(), "Combat is now turn based.") {
+		t.Errorf("history was not replayed: %+v", second)
+	}
+	if !strings.Contains(second[2].Text(), "turn based with positioning") {
+		t.Errorf("the last turn should hold the current document: %s", second[2].Text())
+	}
+
+	session, err := chat.Session(doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Turns) != 4 || session.Turns[1].Version != 2 {
+		t.Errorf("unexpected session %+v", session)
+	}
+
+	if err := chat.Reset(doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if session, _ := chat.Session(doc.ID); len(session.Turns) != 0 {
+		t.Errorf("expected an empty session after reset, got %+v", session)
+	}
+
+	if _, err := chat.Send(context.Background(), "missing", "hello"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
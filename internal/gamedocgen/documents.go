@@ -0,0 +1,288 @@
+package gamedocgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gdrsapi/pkg/jsondiff"
+	"gdrsapi/pkg/store"
+	"sync"
+	"time"
+)
+
+const (
+	documentsCollection = "designdocs"
+	versionsCollection  = "designdoc_versions"
+
+	ActionGenerate   = "generate"
+	ActionRegenerate = "regenerate"
+	ActionRevert     = "revert"
+)
+
+var (
+	ErrDocumentNotFound = errors.New("design document not found")
+	ErrVersionNotFound  = errors.New("design document version not found")
+)
+
+// StoredDocument is a design document kept on the server. Its content lives
+// in versions, CurrentVersion is the one edits build on.
+type StoredDocument struct {
+	ID             string    `json:"id"`
+	Template       string    `json:"template"`
+	Title          string    `json:"title"`
+	CurrentVersion int       `json:"currentVersion"`
+	VersionCount   int       `json:"versionCount"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// Version is an immutable snapshot of a document with the request that
+// produced it.
+type Version struct {
+	Number       int             `json:"number"`
+	Action       string          `json:"action"`
+	Prompt       string          `json:"prompt,omitempty"`
+	Suggestion   string          `json:"suggestion,omitempty"`
+	Selection    string          `json:"selection,omitempty"`
+	Path         string          `json:"path,omitempty"`
+	RevertedFrom int             `json:"revertedFrom,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	Document     json.RawMessage `json:"document,omitempty"`
+}
+
+type DocumentStore struct {
+	mu    sync.Mutex
+	store *store.Store
+}
+
+func NewDocumentStore(store *store.Store) *DocumentStore {
+	return &DocumentStore{store: store}
+}
+
+// Create stores a new document with version as its first version.
+func (d *DocumentStore) Create(template string, title string, version Version) (*StoredDocument, *Version, error) {
+	now := time.Now().UTC()
+	doc := &StoredDocument{
+		ID:        newDocumentId(),
+		Template:  template,
+		Title:     title,
+		CreatedAt: now,
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	v, err := d.addVersion(doc, version, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, v, nil
+}
+
+// AddVersion stores version as the newest version of the document and makes
+// it the current one.
+func (d *DocumentStore) AddVersion(id string, version Version) (*StoredDocument, *Version, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, err := d.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v, err := d.addVersion(doc, version, time.Now().UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, v, nil
+}
+
+// Revert adds a version holding the document as it was in version n. Earlier
+// versions are kept, so a revert can itself be reverted.
+func (d *DocumentStore) Revert(id string, n int) (*StoredDocument, *Version, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, err := d.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := d.version(doc, n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v, err := d.addVersion(doc, Version{
+		Action:       ActionRevert,
+		RevertedFrom: target.Number,
+		Document:     target.Document,
+	}, time.Now().UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, v, nil
+}
+
+func (d *DocumentStore) addVersion(doc *StoredDocument, version Version, now time.Time) (*Version, error) {
+	version.Number = doc.VersionCount + 1
+	version.CreatedAt = now
+	if err := d.store.Put(versionsCollection, versionKey(doc.ID, version.Number), version); err != nil {
+		return nil, fmt.Errorf("saving design document version: %w", err)
+	}
+
+	doc.VersionCount = version.Number
+	doc.CurrentVersion = version.Number
+	doc.UpdatedAt = now
+	if err := d.store.Put(documentsCollection, doc.ID, doc); err != nil {
+		return nil, fmt.Errorf("saving design document: %w", err)
+	}
+	return &version, nil
+}
+
+func (d *DocumentStore) Get(id string) (*StoredDocument, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.get(id)
+}
+
+func (d *DocumentStore) get(id string) (*StoredDocument, error) {
+	var doc StoredDocument
+	err := d.store.Get(documentsCollection, id, &doc)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Current returns the document with its current version.
+func (d *DocumentStore) Current(id string) (*StoredDocument, *Version, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, err := d.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := d.version(doc, doc.CurrentVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, v, nil
+}
+
+// Version returns version n of the document.
+func (d *DocumentStore) Version(id string, n int) (*Version, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, err := d.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.version(doc, n)
+}
+
+func (d *DocumentStore) version(doc *StoredDocument, n int) (*Version, error) {
+	if n < 1 || n > doc.VersionCount {
+		return nil, ErrVersionNotFound
+	}
+
+	var v Version
+	err := d.store.Get(versionsCollection, versionKey(doc.ID, n), &v)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Versions lists every version of the document, oldest first, without their
+// content.
+func (d *DocumentStore) Versions(id string) ([]Version, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, err := d.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Version, 0, doc.VersionCount)
+	for n := 1; n <= doc.VersionCount; n++ {
+		v, err := d.version(doc, n)
+		if err != nil {
+			return nil, err
+		}
+		v.Document = nil
+		versions = append(versions, *v)
+	}
+	return versions, nil
+}
+
+// Diff lists the changes between versions from and to of the document.
+func (d *DocumentStore) Diff(id string, from int, to int) ([]jsondiff.Change, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, err := d.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var values [2]interface{}
+	for i, n := range []int{from, to} {
+		v, err := d.version(doc, n)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(v.Document, &values[i]); err != nil {
+			return nil, fmt.Errorf("decoding version %d: %w", n, err)
+		}
+	}
+	return jsondiff.Diff(values[0], values[1]), nil
+}
+
+// MergeSections applies the sections of a partial document, as returned by a
+// selection based regeneration, on top of the current document. Empty
+// sections are left out of the reply and keep their current value. The
+// partial document must be the model's raw reply: a decoded template document
+// would carry every section it left out as an object of empty fields.
+func MergeSections(current []byte, partial json.RawMessage) (json.RawMessage, error) {
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(current, &merged); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocument, err)
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(partial, &sections); err != nil {
+		return nil, err
+	}
+
+	for key, value := range sections {
+		switch string(value) {
+		case "null", `""`, "[]", "{}":
+			continue
+		}
+		merged[key] = value
+	}
+	return json.Marshal(merged)
+}
+
+func versionKey(id string, n int) string {
+	return fmt.Sprintf("%s_%d", id, n)
+}
+
+func newDocumentId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating design document id: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
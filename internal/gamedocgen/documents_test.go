@@ -0,0 +1,94 @@
+package gamedocgen
+
+import (
+	"encoding/json"
+	"errors"
+	"gdrsapi/pkg/jsondiff"
+	"gdrsapi/pkg/store"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentVersions(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := NewDocumentStore(s)
+
+	doc, v1, err := docs.Create("basic", "Dream Architect", Version{
+		Action:   ActionGenerate,
+		Prompt:   "A surreal puzzle game",
+		Document: json.RawMessage(`{"overview": "First", "keyFeatures": ["Dreams"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.Number != 1 || doc.CurrentVersion != 1 {
+		t.Errorf("expected version 1, got %d current %d", v1.Number, doc.CurrentVersion)
+	}
+
+	_, v2, err := docs.AddVersion(doc.ID, Version{
+		Action:     ActionRegenerate,
+		Path:       "overview",
+		Suggestion: "shorter",
+		Document:   json.RawMessage(`{"overview": "Second", "keyFeatures": ["Dreams"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, v3, err := docs.Revert(doc.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3.Number != 3 || v3.RevertedFrom != 1 {
+		t.Errorf("unexpected revert %+v", v3)
+	}
+	if changes, err := docs.Diff(doc.ID, 1, 3); err != nil || len(changes) != 0 {
+		t.Errorf("expected the revert to match version 1, got %+v %v", changes, err)
+	}
+
+	current, latest, err := docs.Current(doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.CurrentVersion != 3 || current.VersionCount != 3 || latest.Number != 3 {
+		t.Errorf("expected version 3 to be current, got %+v", current)
+	}
+
+	versions, err := docs.Versions(doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 || versions[1].Suggestion != "shorter" || versions[1].Document != nil {
+		t.Errorf("unexpected versions %+v", versions)
+	}
+
+	changes, err := docs.Diff(doc.ID, v1.Number, v2.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []jsondiff.Change{{Op: jsondiff.OpReplace, Path: "overview", Old: "First", New: "Second"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("unexpected diff %+v", changes)
+	}
+
+	if _, err := docs.Version(doc.ID, 4); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("expected ErrVersionNotFound, got %v", err)
+	}
+	if _, _, err := docs.Revert("missing", 1); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestMergeSections(t *testing.T) {
+	partial := json.RawMessage(`{"overview": "New", "keyFeatures": []}`)
+	merged, err := MergeSections([]byte(`{"overview": "Old", "keyFeatures": ["Dreams"]}`), partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(merged) != `{"keyFeatures":["Dreams"],"overview":"New"}` {
+		t.Errorf("unexpected merge %s", merged)
+	}
+}
@@ -0,0 +1,385 @@
+package gamedocgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gdrsapi/pkg/docx"
+	"gdrsapi/pkg/pdf"
+	"html/template"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	ExportJSON     = "json"
+	ExportMarkdown = "md"
+	ExportHTML     = "html"
+	ExportPDF      = "pdf"
+	ExportDOCX     = "docx"
+)
+
+var ErrUnknownExportFormat = errors.New("unknown export format, use md, html, pdf or docx")
+
+// exportSection is a document section in a shape every format can render.
+// Nested objects become subsections.
+type exportSection struct {
+	Title       string
+	Paragraphs  []string
+	Items       []string
+	Subsections []exportSection
+}
+
+type exportDoc struct {
+	Title    string
+	Sections []exportSection
+}
+
+// ExportGameDesignDoc renders a template's document, given as its struct or
+// as raw json, in the given format using the template's section titles. It
+// returns the file and its content type.
+func ExportGameDesignDoc(doc interface{}, tmpl *Template, title string, format string) ([]byte, string, error) {
+	ed, err := newExportDoc(doc, tmpl, title)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch format {
+	case ExportMarkdown:
+		return renderMarkdown(ed), "text/markdown; charset=utf-8", nil
+	case ExportHTML:
+		var buf bytes.Buffer
+		if err := htmlExport.Execute(&buf, ed); err != nil {
+			return nil, "", fmt.Errorf("rendering html: %w", err)
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+	case ExportPDF:
+		return renderPdf(ed), "application/pdf", nil
+	case ExportDOCX:
+		out, err := renderDocx(ed)
+		if err != nil {
+			return nil, "", err
+		}
+		return out, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", nil
+	}
+
+	return nil, "", ErrUnknownExportFormat
+}
+
+// ValidExportFormat reports whether format is json or one of the export formats.
+func ValidExportFormat(format string) bool {
+	switch format {
+	case ExportJSON, ExportMarkdown, ExportHTML, ExportPDF, ExportDOCX:
+		return true
+	}
+	return false
+}
+
+// ExportFileName returns a file name for an exported document.
+func ExportFileName(title string, format string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		case sb.Len() > 0 && !strings.HasSuffix(sb.String(), "-"):
+			sb.WriteRune('-')
+		}
+	}
+
+	name := strings.Trim(sb.String(), "-")
+	if name == "" {
+		name = "game-design-document"
+	}
+	return name + "." + format
+}
+
+func newExportDoc(doc interface{}, tmpl *Template, title string) (*exportDoc, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encoding document: %w", err)
+	}
+
+	value, err := decodeOrdered(json.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+
+	fields, ok := value.([]field)
+	if !ok {
+		return nil, fmt.Errorf("invalid document: expected a json object")
+	}
+
+	if title == "" {
+		title = "Game Design Document"
+	}
+	ed := &exportDoc{Title: title}
+
+	// template sections first, in template order, then anything else
+	used := make(map[string]bool)
+	for _, section := range tmpl.Sections {
+		for _, f := range fields {
+			if f.Key == section.Key {
+				ed.Sections = append(ed.Sections, newExportSection(section.Title, f.Value, section.Sections))
+				used[f.Key] = true
+			}
+		}
+	}
+	for _, f := range fields {
+		if !used[f.Key] {
+			ed.Sections = append(ed.Sections, newExportSection(humanize(f.Key), f.Value, nil))
+		}
+	}
+	return ed, nil
+}
+
+// newExportSection converts a value to a section. Nested sections of the
+// template name the object fields, other fields are named after their key.
+func newExportSection(title string, value interface{}, nested []Section) exportSection {
+	section := exportSection{Title: title}
+
+	switch v := value.(type) {
+	case []field:
+		for _, f := range v {
+			sub := Section{Title: humanize(f.Key)}
+			for _, s := range nested {
+				if s.Key == f.Key {
+					sub = s
+				}
+			}
+			section.Subsections = append(section.Subsections, newExportSection(sub.Title, f.Value, sub.Sections))
+		}
+	case []interface{}:
+		for i, item := range v {
+			if fields, ok := item.([]field); ok {
+				section.Subsections = append(section.Subsections, newExportSection(itemTitle(fields, i), fields, nested))
+				continue
+			}
+			if s := scalarText(item); s != "" {
+				section.Items = append(section.Items, s)
+			}
+		}
+	default:
+		if s := scalarText(v); s != "" {
+			section.Paragraphs = strings.Split(s, "\n\n")
+		}
+	}
+	return section
+}
+
+// itemTitle names an object inside a list by its name or title field.
+func itemTitle(fields []field, i int) string {
+	for _, f := range fields {
+		if f.Key == "name" || f.Key == "title" {
+			if s, ok := f.Value.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return fmt.Sprintf("Item %d", i+1)
+}
+
+func scalarText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// humanize turns a json key such as artStyleAndAtmosphere into a title.
+func humanize(key string) string {
+	var sb strings.Builder
+	for i, r := range key {
+		switch {
+		case i == 0:
+			sb.WriteRune(unicode.ToUpper(r))
+		case r == '_' || r == '-':
+			sb.WriteRune(' ')
+		case unicode.IsUpper(r):
+			sb.WriteRune(' ')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// field is an object member. Objects are decoded as []field so sections keep
+// the order the model wrote them in.
+type field struct {
+	Key   string
+	Value interface{}
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			var fields []field
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, field{Key: keyTok.(string), Value: value})
+			}
+			_, err := dec.Token()
+			return fields, err
+		case '[':
+			items := []interface{}{}
+			for dec.More() {
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, value)
+			}
+			_, err := dec.Token()
+			return items, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %s", t)
+	default:
+		return t, nil
+	}
+}
+
+func renderMarkdown(ed *exportDoc) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", ed.Title)
+	for _, s := range ed.Sections {
+		writeMarkdownSection(&buf, s, 2)
+	}
+	return buf.Bytes()
+}
+
+func writeMarkdownSection(w io.Writer, s exportSection, level int) {
+	fmt.Fprintf(w, "\n%s %s\n", strings.Repeat("#", min(level, 6)), s.Title)
+	for _, p := range s.Paragraphs {
+		fmt.Fprintf(w, "\n%s\n", p)
+	}
+	if len(s.Items) > 0 {
+		fmt.Fprintln(w)
+		for _, item := range s.Items {
+			fmt.Fprintf(w, "- %s\n", strings.ReplaceAll(item, "\n", "\n  "))
+		}
+	}
+	for _, sub := range s.Subsections {
+		writeMarkdownSection(w, sub, level+1)
+	}
+}
+
+var htmlExport = template.Must(template.New("export").Funcs(map[string]interface{}{
+	"heading": func(level int) string { return fmt.Sprint(min(level, 6)) },
+	"next":    func(level int) int { return level + 1 },
+	"section": func(s exportSection, level int) map[string]interface{} {
+		return map[string]interface{}{"S": s, "Level": level}
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; max-width: 820px; margin: 2rem auto; padding: 0 1rem; line-height: 1.6; }
+  h2 { border-bottom: 1px solid #ddd; padding-bottom: 0.25rem; margin-top: 2rem; }
+  li { margin: 0.3rem 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Sections}}{{template "section" section . 2}}{{end}}
+</body>
+</html>
+{{define "section"}}
+<section>
+<h{{heading .Level}}>{{.S.Title}}</h{{heading .Level}}>
+{{- range .S.Paragraphs}}
+<p>{{.}}</p>
+{{- end}}
+{{- if .S.Items}}
+<ul>
+{{- range .S.Items}}
+  <li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- $level := next .Level}}
+{{- range .S.Subsections}}{{template "section" section . $level}}{{end}}
+</section>
+{{- end}}`))
+
+func renderPdf(ed *exportDoc) []byte {
+	doc := pdf.New()
+	doc.SetFont(true, 22)
+	doc.Paragraph(ed.Title)
+	for _, s := range ed.Sections {
+		writePdfSection(doc, s, 0)
+	}
+	return doc.Bytes()
+}
+
+func writePdfSection(doc *pdf.Document, s exportSection, depth int) {
+	if depth == 0 {
+		doc.Rule()
+	} else {
+		doc.Space(4)
+	}
+	doc.SetFont(true, max(16-float64(depth)*2, 11))
+	doc.Paragraph(s.Title)
+	doc.SetFont(false, 11)
+
+	for _, p := range s.Paragraphs {
+		doc.Paragraph(p)
+		doc.Space(4)
+	}
+	for _, item := range s.Items {
+		doc.ParagraphIndent("• "+item, 12)
+		doc.Space(2)
+	}
+	for _, sub := range s.Subsections {
+		writePdfSection(doc, sub, depth+1)
+	}
+}
+
+func renderDocx(ed *exportDoc) ([]byte, error) {
+	doc := docx.New()
+	doc.Heading(1, ed.Title)
+	for _, s := range ed.Sections {
+		writeDocxSection(doc, s, 2)
+	}
+	return doc.Bytes()
+}
+
+func writeDocxSection(doc *docx.Document, s exportSection, level int) {
+	doc.Heading(level, s.Title)
+	for _, p := range s.Paragraphs {
+		doc.Paragraph(p)
+	}
+	for _, item := range s.Items {
+		doc.Bullet(item)
+	}
+	for _, sub := range s.Subsections {
+		writeDocxSection(doc, sub, level+1)
+	}
+}
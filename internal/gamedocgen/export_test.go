@@ -0,0 +1,109 @@
+package gamedocgen
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testTemplate(t *testing.T, name string) *Template {
+	t.Helper()
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := templates.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
+
+func TestExportGameDesignDoc(t *testing.T) {
+	starter := testTemplate(t, "starter")
+	doc := &StarterGameDesignDocContent{
+		Description:   "A surreal puzzle-platformer.",
+		CoreMechanics: []string{"Reality Bending: Rotate <dream> rooms", "Time Dilation"},
+	}
+
+	md, _, err := ExportGameDesignDoc(doc, starter, "Dream Architect", ExportMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Dream Architect\n\n## Description\n\nA surreal puzzle-platformer.\n\n## Unique Selling Point\n"
+	if !strings.HasPrefix(string(md), want) {
+		t.Errorf("unexpected markdown:\n%s", md)
+	}
+	if !strings.Contains(string(md), "## Core Mechanics\n\n- Reality Bending: Rotate <dream> rooms\n- Time Dilation\n") {
+		t.Errorf("expected core mechanics as a list:\n%s", md)
+	}
+
+	html, _, err := ExportGameDesignDoc(doc, starter, "Dream Architect", ExportHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "<h2>Art Style &amp; Atmosphere</h2>") || !strings.Contains(string(html), "Rotate &lt;dream&gt; rooms") {
+		t.Errorf("unexpected html:\n%s", html)
+	}
+
+	pdfOut, _, err := ExportGameDesignDoc(doc, starter, "Dream Architect", ExportPDF)
+	if err != nil || !bytes.HasPrefix(pdfOut, []byte("%PDF")) {
+		t.Errorf("expected a pdf, got error %v", err)
+	}
+
+	docxOut, _, err := ExportGameDesignDoc(doc, starter, "Dream Architect", ExportDOCX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zip.NewReader(bytes.NewReader(docxOut), int64(len(docxOut))); err != nil {
+		t.Errorf("expected a docx zip: %s", err)
+	}
+
+	if _, _, err := ExportGameDesignDoc(doc, starter, "", "odt"); err != ErrUnknownExportFormat {
+		t.Errorf("expected ErrUnknownExportFormat, got %v", err)
+	}
+}
+
+func TestExportNestedSections(t *testing.T) {
+	basic := testTemplate(t, "basic")
+	raw := json.RawMessage(`{"overview": "Short.", "extraNotes": {"risks": ["Scope"], "characters": [{"name": "Ada", "role": "Guide"}]}}`)
+
+	md, _, err := ExportGameDesignDoc(raw, basic, "Nested", ExportMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Extra Notes", "### Risks\n\n- Scope", "#### Ada", "##### Role\n\nGuide"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("markdown is missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestExportTemplateSubsectionTitles(t *testing.T) {
+	comprehensive := testTemplate(t, "comprehensive")
+	doc := &ComprehensiveGameDesignDocContent{
+		UiUx:       UiUx{Hud: []string{"Health ring"}},
+		Milestones: []Milestone{{Name: "Vertical Slice", Timeframe: "Month 6"}},
+	}
+
+	md, _, err := ExportGameDesignDoc(doc, comprehensive, "Nested", ExportMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## UI/UX", "### HUD\n\n- Health ring", "## Milestones & Roadmap", "### Vertical Slice", "#### Timeframe\n\nMonth 6"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("markdown is missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestExportFileName(t *testing.T) {
+	if name := ExportFileName("Dream Architect: Reborn!", ExportPDF); name != "dream-architect-reborn.pdf" {
+		t.Errorf("unexpected file name %s", name)
+	}
+	if name := ExportFileName("", ExportMarkdown); name != "game-design-document.md" {
+		t.Errorf("unexpected file name %s", name)
+	}
+}
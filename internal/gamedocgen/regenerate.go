@@ -0,0 +1,184 @@
+package gamedocgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gdrsapi/internal/usage"
+	"gdrsapi/pkg/jsondiff"
+	"gdrsapi/pkg/jsonpath"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/schema"
+	"strings"
+)
+
+var ErrInvalidDocument = errors.New("invalid document")
+
+// SectionRegeneration is a document after one of its values was regenerated,
+// with the changes made to it.
+type SectionRegeneration struct {
+	Path     string            `json:"path"`
+	Document interface{}       `json:"document"`
+	Diff     []jsondiff.Change `json:"diff"`
+}
+
+// RegenerateSection rewrites the value at path, such as overview or
+// coreMechanics[3], following the suggestion. The new value is merged into the
+// document, which has to stay valid for the template. A list index one past
+// the end adds a new item.
+func (g *GameDesignDocGen) RegenerateSection(ctx context.Context, currentDocContent string, path string, suggestion string, template string) (*SectionRegeneration, error) {
+	tmpl, err := g.templates.Get(template)
+	if err != nil {
+		return nil, err
+	}
+	ctx = usage.WithAttribution(ctx, usage.Attribution{Template: tmpl.Name})
+
+	p, err := jsonpath.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	docSchema := tmpl.Schema()
+	target, err := schemaAt(docSchema, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := docSchema.Validate([]byte(currentDocContent)); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocument, err)
+	}
+
+	// the original is kept for the diff, the working copy gets the new value
+	var original, working interface{}
+	if err := json.Unmarshal([]byte(currentDocContent), &original); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocument, err)
+	}
+	if err := json.Unmarshal([]byte(currentDocContent), &working); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocument, err)
+	}
+
+	// an index past the end fails here, before the model is called
+	current, _ := jsonpath.Get(original, p)
+	if _, err := jsonpath.Set(working, p, current); err != nil {
+		return nil, err
+	}
+
+	currentValue, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	replySchema := schema.Object().Property("value", target)
+	prompt := GetRegenerateSectionPrompt(currentDocContent, p.String(), string(currentValue), suggestion, tmpl, target)
+
+	respBytes, err := g.send(ctx, g.request(prompt, replySchema))
+	if err != nil {
+		g.logger.ErrorContext(ctx, "sending gemini request", "err", err)
+		return nil, err
+	}
+
+	g.logger.DebugContext(ctx, "gemini response", logger.Sensitive("response", string(respBytes)))
+
+	if err := replySchema.Validate(respBytes); err != nil {
+		g.logger.ErrorContext(ctx, "invalid gemini response", "err", err, logger.Sensitive("response", string(respBytes)))
+		return nil, err
+	}
+
+	var reply struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(respBytes, &reply); err != nil {
+		g.logger.ErrorContext(ctx, "decoding gemini response", "err", err)
+		return nil, err
+	}
+
+	updated, err := jsonpath.Set(working, p, reply.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedBytes, err := json.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+	if err := docSchema.Validate(updatedBytes); err != nil {
+		return nil, fmt.Errorf("%w after regenerating %s: %s", ErrInvalidDocument, p, err)
+	}
+
+	doc := tmpl.NewDoc()
+	if err := json.Unmarshal(updatedBytes, doc); err != nil {
+		g.logger.ErrorContext(ctx, "decoding document", "err", err)
+		return nil, err
+	}
+
+	return &SectionRegeneration{
+		Path:     p.String(),
+		Document: doc,
+		Diff:     jsondiff.Diff(original, updated),
+	}, nil
+}
+
+// schemaAt returns the schema of the value the path points to.
+func schemaAt(s *schema.Schema, p jsonpath.Path) (*schema.Schema, error) {
+	for i, step := range p {
+		if step.IsKey {
+			next, ok := s.Properties[step.Key]
+			if !ok || s.Type != schema.TypeObject {
+				return nil, fmt.Errorf("%w: the template has no %s", jsonpath.ErrInvalidPath, p[:i+1])
+			}
+			s = next
+			continue
+		}
+
+		if s.Type != schema.TypeArray {
+			return nil, fmt.Errorf("%w: %s is not a list", jsonpath.ErrInvalidPath, p[:i])
+		}
+		s = s.Items
+	}
+	return s, nil
+}
+
+func GetRegenerateSectionPrompt(currentDocument, path, currentValue, suggestion string, tmpl *Template, target *schema.Schema) string {
+	return fmt.Sprintf(`
+	You are tasked with editing/refining a specific part of an existing %s game design document. Use your expertise to do as you are asked on the selected part while maintaining consistency with the overall game concept.
+
+	1. Review the current game design document content below:
+	%s
+
+	2. Focus on the part of the document at %s. This is its current value (null means it does not exist yet and has to be written):
+	%s
+
+	3. Consider this suggestion/demand or context for the regeneration/update:
+	%s
+
+	4. Write the new value, ensuring it:
+	- Aligns with the overall game concept and style
+	- Expands upon or improves the existing ideas
+	- Incorporates the additional suggestion or context provided
+	- Maintains a consistent tone and level of detail with the rest of the document
+
+	5. Return only the new value under "value", with the same shape as the current one (%s). Do not return the rest of the document.
+
+	6. Remember to be creative, detailed, and consistent with the existing game design while incorporating improvements and suggestions.`,
+		tmpl.Title,
+		currentDocument,
+		path,
+		currentValue,
+		suggestion,
+		describeSchema(target),
+	)
+}
+
+// describeSchema names the shape of a value for the prompt.
+func describeSchema(s *schema.Schema) string {
+	switch s.Type {
+	case schema.TypeArray:
+		if s.Items.Type == schema.TypeObject {
+			return "a list of objects with the fields " + strings.Join(s.Items.PropertyOrdering, ", ")
+		}
+		return "a list of texts"
+	case schema.TypeObject:
+		return "an object with the fields " + strings.Join(s.PropertyOrdering, ", ")
+	}
+	return "a text"
+}
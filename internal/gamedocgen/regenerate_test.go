@@ -0,0 +1,155 @@
+package gamedocgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gdrsapi/pkg/jsondiff"
+	"gdrsapi/pkg/jsonpath"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/schema"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const starterDoc = `{
+	"description": "A surreal puzzle-platformer.",
+	"uniqueSellingPoint": ["Dream logic"],
+	"gameplayLoop": ["Explore", "Solve"],
+	"coreMechanics": ["Reality Bending", "Time Dilation"],
+	"objectiveAndEndgoal": ["Wake up"],
+	"artStyleAndAtmosphere": ["Soft pastels"]
+}`
+
+func TestRegenerateSection(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prompt string
+	gen := &GameDesignDocGen{
+		logger:    logger.NewAppLogger(),
+		templates: templates,
+		send: fakeLLM(t, func(p string, responseSchema *schema.Schema) ([]byte, error) {
+			prompt = p
+			if responseSchema.Properties["value"].Type != schema.TypeString {
+				t.Errorf("expected a text value, got %+v", responseSchema.Properties["value"])
+			}
+			return []byte(`{"value": "Gravity Flip: walk on any wall"}`), nil
+		}),
+	}
+
+	regen, err := gen.RegenerateSection(context.Background(), starterDoc, "coreMechanics[1]", "make it about gravity", "starter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, `"Time Dilation"`) || !strings.Contains(prompt, "make it about gravity") {
+		t.Errorf("prompt is missing the current value or suggestion:\n%s", prompt)
+	}
+
+	doc := regen.Document.(*StarterGameDesignDocContent)
+	if !reflect.DeepEqual(doc.CoreMechanics, []string{"Reality Bending", "Gravity Flip: walk on any wall"}) {
+		t.Errorf("value was not merged: %v", doc.CoreMechanics)
+	}
+	want := []jsondiff.Change{{Op: jsondiff.OpReplace, Path: "coreMechanics[1]", Old: "Time Dilation", New: "Gravity Flip: walk on any wall"}}
+	if !reflect.DeepEqual(regen.Diff, want) {
+		t.Errorf("unexpected diff %+v", regen.Diff)
+	}
+
+	// one past the end adds an item
+	regen, err = gen.RegenerateSection(context.Background(), starterDoc, "coreMechanics[2]", "add a mechanic", "starter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regen.Diff[0].Op != jsondiff.OpAdd || len(regen.Document.(*StarterGameDesignDocContent).CoreMechanics) != 3 {
+		t.Errorf("expected an added mechanic, got %+v", regen.Diff)
+	}
+}
+
+func TestRegenerateSectionInvalid(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := &GameDesignDocGen{
+		logger:    logger.NewAppLogger(),
+		templates: templates,
+		send: fakeLLM(t, func(p string, responseSchema *schema.Schema) ([]byte, error) {
+			t.Error("the model should not be called")
+			return nil, nil
+		}),
+	}
+
+	tests := []struct {
+		doc  string
+		path string
+		want error
+	}{
+		{starterDoc, "coreMechanics[5]", jsonpath.ErrInvalidPath},
+		{starterDoc, "story", jsonpath.ErrInvalidPath},
+		{starterDoc, "description[0]", jsonpath.ErrInvalidPath},
+		{`{"description": "Only this"}`, "description", ErrInvalidDocument},
+	}
+	for _, tt := range tests {
+		if _, err := gen.RegenerateSection(context.Background(), tt.doc, tt.path, "", "starter"); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, err)
+		}
+	}
+}
+
+func TestRegenerateSelectionKeepsSections(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := templates.Get("comprehensive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := json.Marshal(fakeValue(tmpl.Schema(), "doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := &GameDesignDocGen{
+		logger:    logger.NewAppLogger(),
+		templates: templates,
+		send: fakeLLM(t, func(p string, responseSchema *schema.Schema) ([]byte, error) {
+			return []byte(`{"overview": "A new overview"}`), nil
+		}),
+	}
+
+	sections, err := gen.RegenerateGameDesignDoc(context.Background(), string(current), "doc.overview", "make it shorter", "comprehensive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := MergeSections(current, sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Schema().Validate(merged); err != nil {
+		t.Fatalf("merged document is invalid: %s", err)
+	}
+
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(current, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(merged, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after["overview"] != "A new overview" {
+		t.Errorf("overview was not regenerated: %v", after["overview"])
+	}
+	for name, value := range before {
+		if name == "overview" {
+			continue
+		}
+		if !reflect.DeepEqual(after[name], value) {
+			t.Errorf("section %s changed:\nbefore %v\nafter  %v", name, value, after[name])
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package gamedocgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/schema"
+	"strings"
+	"sync"
+)
+
+// sectionConcurrency caps the Gemini calls a sectioned document makes at once.
+const sectionConcurrency = 4
+
+// generateSections writes a sectioned template one top level section per call
+// so each reply stays within the output limit. The first section is written
+// on its own and given to the other calls, which run in parallel, so the rest
+// of the document builds on the same concept.
+func (g *GameDesignDocGen) generateSections(ctx context.Context, gameTitle string, gameDescription string, gameGenre string, tmpl *Template) (interface{}, error) {
+	parts := make(map[string]json.RawMessage, len(tmpl.Sections))
+
+	first := tmpl.Sections[0]
+	firstPart, err := g.generateSection(ctx, gameTitle, gameDescription, gameGenre, tmpl, first, "")
+	if err != nil {
+		return nil, err
+	}
+	parts[first.Key] = firstPart
+
+	written, err := json.Marshal(map[string]json.RawMessage{first.Key: firstPart})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		sem      = make(chan struct{}, sectionConcurrency)
+	)
+	for _, section := range tmpl.Sections[1:] {
+		wg.Add(1)
+		go func(section Section) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			part, err := g.generateSection(ctx, gameTitle, gameDescription, gameGenre, tmpl, section, string(written))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			parts[section.Key] = part
+		}(section)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	assembled, err := json.Marshal(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := tmpl.NewDoc()
+	if err := json.Unmarshal(assembled, doc); err != nil {
+		g.logger.ErrorContext(ctx, "decoding document", "err", err)
+		return nil, err
+	}
+	return doc, nil
+}
+
+// generateSection asks for a single section and returns its value.
+func (g *GameDesignDocGen) generateSection(ctx context.Context, gameTitle string, gameDescription string, gameGenre string, tmpl *Template, section Section, written string) (json.RawMessage, error) {
+	prompt := GetSectionPrompt(gameTitle, gameDescription, gameGenre, tmpl, section, written)
+	sch := schema.Object().Property(section.Key, section.Schema())
+
+	respBytes, err := g.send(ctx, g.request(prompt, sch))
+	if err != nil {
+		g.logger.ErrorContext(ctx, "sending gemini request", "err", err)
+		return nil, fmt.Errorf("generating %s: %w", section.Title, err)
+	}
+
+	if err := sch.Validate(respBytes); err != nil {
+		g.logger.ErrorContext(ctx, "invalid gemini response", "err", err, logger.Sensitive("response", string(respBytes)))
+		return nil, fmt.Errorf("generating %s: %w", section.Title, err)
+	}
+
+	var reply map[string]json.RawMessage
+	if err := json.Unmarshal(respBytes, &reply); err != nil {
+		g.logger.ErrorContext(ctx, "decoding gemini response", "err", err)
+		return nil, fmt.Errorf("generating %s: %w", section.Title, err)
+	}
+	return reply[section.Key], nil
+}
+
+func GetSectionPrompt(title, description, genre string, tmpl *Template, section Section, written string) string {
+	if written == "" {
+		written = "Nothing has been written yet, this is the first section."
+	}
+
+	var components strings.Builder
+	for _, s := range tmpl.Sections {
+		components.WriteString("\n\t\t- " + s.Title)
+	}
+
+	return fmt.Sprintf(`
+	You are writing one section of a %s game design document for a video game given its title, description/ideas, and genre. Follow the instructions below.
+
+	1. Use the video game ideas and context below:
+		Here is the title of the game:
+		%s
+
+		Here is the description/ideas of the game:
+		%s
+
+		Here is the genre of the game:
+		%s
+
+	2. The full document has the following components, other writers handle the rest:
+		%s
+
+	3. This is what has been written so far, stay consistent with it:
+	%s
+
+	4. Write only the %s section:
+		%s
+
+	5. Please provide the section content in the following JSON format for the output:
+	`+"```json\n%s\n```"+`
+
+	6. Remember to be creative, detailed, and consistent.`,
+		tmpl.Title,
+		title,
+		description,
+		genre,
+		components.String(),
+		written,
+		section.Title,
+		outlineText([]Section{section}, "\n\t\t"),
+		section.JsonFormat(),
+	)
+}
@@ -0,0 +1,119 @@
+package gamedocgen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gdrsapi/external/gemini"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/schema"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLLM adapts a fake that looks at the prompt and schema to the send
+// hook, checking the request carries the designer persona.
+func fakeLLM(t *testing.T, fn func(prompt string, responseSchema *schema.Schema) ([]byte, error)) func(context.Context, *gemini.Request) ([]byte, error) {
+	return func(ctx context.Context, req *gemini.Request) ([]byte, error) {
+		if req.SystemInstruction == nil || req.SystemInstruction.Text() != designerSystemInstruction {
+			t.Errorf("expected the designer system instruction, got %+v", req.SystemInstruction)
+		}
+		responseSchema, _ := req.GenerationConfig["response_schema"].(*schema.Schema)
+		return fn(req.Contents[len(req.Contents)-1].Text(), responseSchema)
+	}
+}
+
+// fakeValue builds a value that matches the schema, using path as the text.
+func fakeValue(s *schema.Schema, path string) interface{} {
+	switch s.Type {
+	case schema.TypeArray:
+		return []interface{}{fakeValue(s.Items, path+"[0]")}
+	case schema.TypeObject:
+		obj := make(map[string]interface{})
+		for name, p := range s.Properties {
+			obj[name] = fakeValue(p, path+"."+name)
+		}
+		return obj
+	}
+	return path
+}
+
+func TestGenerateSections(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		prompts = make(map[string]string)
+	)
+	gen := &GameDesignDocGen{
+		logger:    logger.NewAppLogger(),
+		templates: templates,
+		send: fakeLLM(t, func(prompt string, responseSchema *schema.Schema) ([]byte, error) {
+			if len(responseSchema.Required) != 1 {
+				t.Errorf("expected one section per call, got %v", responseSchema.Required)
+			}
+			key := responseSchema.Required[0]
+
+			mu.Lock()
+			prompts[key] = prompt
+			mu.Unlock()
+			return json.Marshal(fakeValue(responseSchema, "doc"))
+		}),
+	}
+
+	out, err := gen.GenerateGameDesignDoc(context.Background(), "Dream Architect", "A puzzle game", "Puzzle", "comprehensive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, ok := out.(*ComprehensiveGameDesignDocContent)
+	if !ok {
+		t.Fatalf("expected a comprehensive document, got %T", out)
+	}
+	if doc.Overview != "doc.overview" || doc.Mechanics.Systems[0].SubSystems[0] != "doc.mechanics.systems[0].subSystems[0]" {
+		t.Errorf("sections were not assembled: %+v", doc)
+	}
+	if len(doc.Milestones) != 1 || doc.Milestones[0].Timeframe == "" {
+		t.Errorf("milestones were not assembled: %+v", doc.Milestones)
+	}
+
+	if len(prompts) != 13 {
+		t.Errorf("expected a call per section, got %d", len(prompts))
+	}
+	if !strings.Contains(prompts["overview"], "Nothing has been written yet") {
+		t.Errorf("the overview should be written first")
+	}
+	if !strings.Contains(prompts["audio"], `"overview":"doc.overview"`) {
+		t.Errorf("later sections should see the overview:\n%s", prompts["audio"])
+	}
+}
+
+func TestGenerateSectionsError(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := &GameDesignDocGen{
+		logger:    logger.NewAppLogger(),
+		templates: templates,
+		send: fakeLLM(t, func(prompt string, responseSchema *schema.Schema) ([]byte, error) {
+			switch responseSchema.Required[0] {
+			case "audio":
+				return nil, errors.New("quota exceeded")
+			case "uiUx":
+				return []byte(`{"uiUx": "not an object"}`), nil
+			}
+			return json.Marshal(fakeValue(responseSchema, "doc"))
+		}),
+	}
+
+	_, err = gen.GenerateGameDesignDoc(context.Background(), "Dream Architect", "A puzzle game", "Puzzle", "comprehensive")
+	if err == nil || !(strings.Contains(err.Error(), "Audio") || strings.Contains(err.Error(), "UI/UX")) {
+		t.Errorf("expected a section error, got %v", err)
+	}
+}
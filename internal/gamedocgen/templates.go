@@ -0,0 +1,353 @@
+package gamedocgen
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gdrsapi/pkg/schema"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	FieldString  = "string"
+	FieldList    = "list"
+	FieldObject  = "object"
+	FieldObjects = "objects"
+
+	SourceBuiltin = "builtin"
+	SourceUser    = "user"
+)
+
+var ErrUnknownTemplate = errors.New("unknown template")
+
+//go:embed templates/*.json
+var builtinTemplates embed.FS
+
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// typedDocs binds built-in templates to the structs their documents decode
+// into. Templates without a struct decode into a generic map.
+var typedDocs = map[string]func() interface{}{
+	"basic":         func() interface{} { return &BasicGameDesignDocContent{} },
+	"starter":       func() interface{} { return &StarterGameDesignDocContent{} },
+	"comprehensive": func() interface{} { return &ComprehensiveGameDesignDocContent{} },
+}
+
+// Section is one part of a template's document, stored under Key. Object
+// sections, and each item of an objects section, hold nested Sections.
+type Section struct {
+	Key         string    `json:"key"`
+	Title       string    `json:"title"`
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Sections    []Section `json:"sections,omitempty"`
+}
+
+// Template describes a kind of design document: its sections, the outline
+// given to the model and example documents. Sectioned templates are too long
+// for one reply and are generated one top level section at a time.
+type Template struct {
+	Name        string            `json:"name"`
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Outline     string            `json:"outline,omitempty"`
+	Sectioned   bool              `json:"sectioned,omitempty"`
+	Sections    []Section         `json:"sections"`
+	Examples    []json.RawMessage `json:"examples,omitempty"`
+	Source      string            `json:"source"`
+}
+
+// TemplateRegistry holds the built-in templates and the user templates found
+// in the templates directory.
+type TemplateRegistry struct {
+	templates map[string]*Template
+	names     []string
+}
+
+// LoadTemplates loads the embedded templates and every json file in userDir.
+// A missing userDir is not an error. User templates can not replace built-in
+// ones.
+func LoadTemplates(userDir string) (*TemplateRegistry, error) {
+	r := &TemplateRegistry{templates: make(map[string]*Template)}
+
+	if err := r.loadDir(builtinTemplates, "templates", SourceBuiltin); err != nil {
+		return nil, err
+	}
+
+	if userDir != "" {
+		err := r.loadDir(os.DirFS(userDir), ".", SourceUser)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	slices.Sort(r.names)
+	return r, nil
+}
+
+func (r *TemplateRegistry) loadDir(fsys fs.FS, dir string, source string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".json" {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("reading template %s: %w", e.Name(), err)
+		}
+
+		var t Template
+		if err := json.Unmarshal(data, &t); err != nil {
+			return fmt.Errorf("decoding template %s: %w", e.Name(), err)
+		}
+		t.Source = source
+
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("template %s: %w", e.Name(), err)
+		}
+		if _, exists := r.templates[t.Name]; exists {
+			return fmt.Errorf("template %s: a template named %q already exists", e.Name(), t.Name)
+		}
+
+		r.templates[t.Name] = &t
+		r.names = append(r.names, t.Name)
+	}
+	return nil
+}
+
+func (t *Template) validate() error {
+	if !templateNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("name %q must be lowercase letters, digits, - or _", t.Name)
+	}
+	if t.Title == "" {
+		t.Title = humanize(t.Name)
+	}
+	if len(t.Sections) == 0 {
+		return fmt.Errorf("at least one section is required")
+	}
+	if err := validateSections(t.Sections, ""); err != nil {
+		return err
+	}
+
+	// built-in structs must decode every section
+	if newDoc, ok := typedDocs[t.Name]; ok && t.Source == SourceBuiltin {
+		if err := matchFields(t.Sections, schema.For(newDoc()), ""); err != nil {
+			return fmt.Errorf("%w in the %s struct", err, t.Name)
+		}
+	}
+
+	sch := t.Schema()
+	for i, example := range t.Examples {
+		if err := sch.Validate(example); err != nil {
+			return fmt.Errorf("example %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateSections(sections []Section, parent string) error {
+	keys := make(map[string]bool)
+	for i := range sections {
+		s := &sections[i]
+		if s.Key == "" || keys[s.Key] {
+			return fmt.Errorf("section %d of %s needs a unique key", i+1, sectionPath(parent, ""))
+		}
+		keys[s.Key] = true
+		path := sectionPath(parent, s.Key)
+
+		switch s.Type {
+		case FieldString, FieldList:
+			if len(s.Sections) > 0 {
+				return fmt.Errorf("section %s of type %s can not have sections", path, s.Type)
+			}
+		case FieldObject, FieldObjects:
+			if len(s.Sections) == 0 {
+				return fmt.Errorf("section %s of type %s needs sections", path, s.Type)
+			}
+			if err := validateSections(s.Sections, path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("section %s has type %q, use %s, %s, %s or %s", path, s.Type, FieldString, FieldList, FieldObject, FieldObjects)
+		}
+
+		if s.Title == "" {
+			s.Title = humanize(s.Key)
+		}
+	}
+	return nil
+}
+
+// matchFields checks that every section has a field in the struct schema.
+func matchFields(sections []Section, sch *schema.Schema, parent string) error {
+	for _, s := range sections {
+		path := sectionPath(parent, s.Key)
+		p, ok := sch.Properties[s.Key]
+		if !ok {
+			return fmt.Errorf("section %s has no field", path)
+		}
+		switch s.Type {
+		case FieldObject:
+			if err := matchFields(s.Sections, p, path); err != nil {
+				return err
+			}
+		case FieldObjects:
+			if p.Items == nil {
+				return fmt.Errorf("section %s is not a list", path)
+			}
+			if err := matchFields(s.Sections, p.Items, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sectionPath(parent string, key string) string {
+	switch {
+	case parent == "" && key == "":
+		return "the template"
+	case parent == "":
+		return key
+	case key == "":
+		return parent
+	}
+	return parent + "." + key
+}
+
+// Get returns the template with the given name.
+func (r *TemplateRegistry) Get(name string) (*Template, error) {
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTemplate, name)
+	}
+	return t, nil
+}
+
+// List returns every template sorted by name.
+func (r *TemplateRegistry) List() []*Template {
+	templates := make([]*Template, 0, len(r.names))
+	for _, name := range r.names {
+		templates = append(templates, r.templates[name])
+	}
+	return templates
+}
+
+// GenerateCalls is the model calls it takes to generate a document: one per
+// section for sectioned templates.
+func (t *Template) GenerateCalls() int {
+	if t.Sectioned {
+		return len(t.Sections)
+	}
+	return 1
+}
+
+// NewDoc returns a pointer to the value a document of this template decodes
+// into: the bound struct for built-in templates, a map otherwise.
+func (t *Template) NewDoc() interface{} {
+	if newDoc, ok := typedDocs[t.Name]; ok && t.Source == SourceBuiltin {
+		return newDoc()
+	}
+	return &map[string]interface{}{}
+}
+
+// Schema returns the response schema of a full document.
+func (t *Template) Schema() *schema.Schema {
+	s := schema.Object()
+	for _, section := range t.Sections {
+		s.Property(section.Key, section.Schema())
+	}
+	return s
+}
+
+// Schema returns the schema of the section's value.
+func (s Section) Schema() *schema.Schema {
+	var p *schema.Schema
+	switch s.Type {
+	case FieldList:
+		p = schema.Array(schema.String())
+	case FieldObject, FieldObjects:
+		p = schema.Object()
+		for _, sub := range s.Sections {
+			p.Property(sub.Key, sub.Schema())
+		}
+		if s.Type == FieldObjects {
+			p = schema.Array(p)
+		}
+	default:
+		p = schema.String()
+	}
+	if s.Description != "" {
+		p.Describe(s.Description)
+	}
+	return p
+}
+
+// OutlineText lists the sections the model has to write.
+func (t *Template) OutlineText() string {
+	if t.Outline != "" {
+		return t.Outline
+	}
+	return outlineText(t.Sections, "\n\t\t")
+}
+
+func outlineText(sections []Section, indent string) string {
+	var sb strings.Builder
+	for _, s := range sections {
+		sb.WriteString(indent + "- " + s.Title)
+		if s.Description != "" {
+			sb.WriteString(": " + s.Description)
+		}
+		sb.WriteString(outlineText(s.Sections, indent+"\t"))
+	}
+	return sb.String()
+}
+
+// JsonFormat shows the shape of a document with empty values.
+func (t *Template) JsonFormat() string {
+	return jsonFormat(t.Sections, "\t")
+}
+
+// JsonFormat shows the shape of the section inside its own object.
+func (s Section) JsonFormat() string {
+	return jsonFormat([]Section{s}, "\t")
+}
+
+func jsonFormat(sections []Section, indent string) string {
+	lines := make([]string, 0, len(sections))
+	for _, s := range sections {
+		var value string
+		switch s.Type {
+		case FieldList:
+			value = `"[]"`
+		case FieldObject:
+			value = jsonFormat(s.Sections, indent+"\t")
+		case FieldObjects:
+			value = "[" + jsonFormat(s.Sections, indent+"\t") + "]"
+		default:
+			value = `" "`
+		}
+		lines = append(lines, fmt.Sprintf("%s\t%q: %s", indent, s.Key, value))
+	}
+	return "{\n" + strings.Join(lines, ",\n") + "\n" + indent + "}"
+}
+
+// ExamplesText numbers the template's examples for the prompt.
+func (t *Template) ExamplesText() string {
+	var sb strings.Builder
+	for i, example := range t.Examples {
+		fmt.Fprintf(&sb, "\n\tEXAMPLE %d:\n\t%s\n", i+1, example)
+	}
+	return sb.String()
+}
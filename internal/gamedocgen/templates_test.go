@@ -0,0 +1,109 @@
+package gamedocgen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadBuiltinTemplates(t *testing.T) {
+	templates, err := LoadTemplates(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, tmpl := range templates.List() {
+		names = append(names, tmpl.Name)
+		if tmpl.Source != SourceBuiltin {
+			t.Errorf("%s should be a builtin template", tmpl.Name)
+		}
+	}
+	if strings.Join(names, ",") != "basic,comprehensive,pitch,starter" {
+		t.Errorf("unexpected templates %v", names)
+	}
+
+	starter, err := templates.Get("starter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := starter.NewDoc().(*StarterGameDesignDocContent); !ok {
+		t.Errorf("starter documents should decode into StarterGameDesignDocContent")
+	}
+	if !strings.Contains(GetGeneratePrompt("Dream Architect", "A puzzle game", "Puzzle", starter), `"coreMechanics": "[]"`) {
+		t.Errorf("generate prompt is missing the json format")
+	}
+
+	comprehensive, err := templates.Get("comprehensive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if starter.GenerateCalls() != 1 || comprehensive.GenerateCalls() != len(comprehensive.Sections) {
+		t.Errorf("sectioned templates take a call per section, got %d and %d", starter.GenerateCalls(), comprehensive.GenerateCalls())
+	}
+
+	if _, err := templates.Get("novel"); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("expected ErrUnknownTemplate, got %v", err)
+	}
+}
+
+func TestLoadUserTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "postmortem.json", `{
+		"name": "postmortem",
+		"sections": [
+			{"key": "summary", "type": "string"},
+			{"key": "wentWell", "title": "What Went Well", "type": "list"}
+		],
+		"examples": [{"summary": "Shipped on time.", "wentWell": ["Scope"]}]
+	}`)
+	writeTemplate(t, dir, "notes.txt", "not a template")
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := templates.Get("postmortem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Source != SourceUser || tmpl.Title != "Postmortem" || tmpl.Sections[0].Title != "Summary" {
+		t.Errorf("unexpected template %+v", tmpl)
+	}
+	if _, ok := tmpl.NewDoc().(*map[string]interface{}); !ok {
+		t.Errorf("user template documents should decode into a map")
+	}
+	if err := tmpl.Schema().Validate([]byte(`{"summary": "Late.", "wentWell": []}`)); err != nil {
+		t.Errorf("expected a valid document: %s", err)
+	}
+}
+
+func TestLoadInvalidTemplates(t *testing.T) {
+	tests := map[string]string{
+		"duplicate name": `{"name": "basic", "sections": [{"key": "a", "type": "string"}]}`,
+		"bad name":       `{"name": "Post Mortem", "sections": [{"key": "a", "type": "string"}]}`,
+		"no sections":    `{"name": "empty", "sections": []}`,
+		"bad type":       `{"name": "typed", "sections": [{"key": "a", "type": "number"}]}`,
+		"bad example":    `{"name": "example", "sections": [{"key": "a", "type": "list"}], "examples": [{"a": "text"}]}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplate(t, dir, "template.json", body)
+			if _, err := LoadTemplates(dir); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func writeTemplate(t *testing.T, dir string, name string, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Job tracks the progress of long running work. Result is replaced instead
+// of mutated on every update so snapshots can be encoded without a lock.
+type Job struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Status     string      `json:"status"`
+	Total      int         `json:"total"`
+	Completed  int         `json:"completed"`
+	Failed     int         `json:"failed"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+
+	done chan struct{}
+}
+
+// Manager keeps jobs in memory until they expire.
+type Manager struct {
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	retention time.Duration
+}
+
+func NewManager(retention time.Duration) *Manager {
+	return &Manager{
+		jobs:      make(map[string]*Job),
+		retention: retention,
+	}
+}
+
+func newJobId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating job id: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// Create registers a queued job with the amount of work items it will process.
+func (m *Manager) Create(kind string, total int) Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	job := &Job{
+		ID:        newJobId(),
+		Kind:      kind,
+		Status:    StatusQueued,
+		Total:     total,
+		CreatedAt: now,
+		UpdatedAt: now,
+		done:      make(chan struct{}),
+	}
+	m.jobs[job.ID] = job
+	return *job
+}
+
+// Get returns a snapshot of the job.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Update changes a job under the manager lock and marks queued jobs as running.
+func (m *Manager) Update(id string, fn func(job *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+
+	if job.Status == StatusQueued {
+		job.Status = StatusRunning
+	}
+	fn(job)
+	job.UpdatedAt = time.Now()
+}
+
+// Finish completes the job, failing it when err is not nil.
+func (m *Manager) Finish(id string, result interface{}, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	job.Status = StatusCompleted
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	}
+	if result != nil {
+		job.Result = result
+	}
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	close(job.done)
+}
+
+// Wait blocks until the job finishes or the timeout passes and returns the
+// latest snapshot.
+func (m *Manager) Wait(id string, timeout time.Duration) (Job, bool) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+
+	select {
+	case <-job.done:
+	case <-time.After(timeout):
+	}
+	return m.Get(id)
+}
+
+// RemoveExpired drops finished jobs older than the retention period.
+func (m *Manager) RemoveExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, job := range m.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > m.retention {
+			delete(m.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(time.Hour)
+
+	job := m.Create("test", 3)
+	if job.ID == "" || job.Status != StatusQueued || job.Total != 3 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	m.Update(job.ID, func(j *Job) {
+		j.Completed++
+		j.Result = []string{"first"}
+	})
+	got, ok := m.Get(job.ID)
+	if !ok || got.Status != StatusRunning || got.Completed != 1 {
+		t.Errorf("expected a running job with one item done, got %+v", got)
+	}
+
+	// snapshots do not change with later updates
+	m.Update(job.ID, func(j *Job) { j.Completed++ })
+	if got.Completed != 1 {
+		t.Errorf("expected the snapshot to keep its progress, got %d", got.Completed)
+	}
+
+	m.Finish(job.ID, nil, nil)
+	got, _ = m.Get(job.ID)
+	if got.Status != StatusCompleted || got.FinishedAt == nil || got.Completed != 2 {
+		t.Errorf("expected a completed job, got %+v", got)
+	}
+	if result, _ := got.Result.([]string); len(result) != 1 {
+		t.Errorf("expected a nil result to keep the last one, got %v", got.Result)
+	}
+
+	failed := m.Create("test", 1)
+	m.Finish(failed.ID, "partial", errors.New("scraping failed"))
+	got, _ = m.Get(failed.ID)
+	if got.Status != StatusFailed || got.Error != "scraping failed" || got.Result != "partial" {
+		t.Errorf("expected a failed job, got %+v", got)
+	}
+
+	if _, ok := m.Get("missing"); ok {
+		t.Error("expected an unknown job to be missing")
+	}
+	// updating or finishing an unknown job is a no-op
+	m.Update("missing", func(j *Job) { t.Error("unexpected update of an unknown job") })
+	m.Finish("missing", nil, nil)
+}
+
+func TestWait(t *testing.T) {
+	m := NewManager(time.Hour)
+
+	job := m.Create("test", 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Finish(job.ID, "done", nil)
+	}()
+
+	got, ok := m.Wait(job.ID, time.Second)
+	if !ok || got.Status != StatusCompleted || got.Result != "done" {
+		t.Errorf("expected the finished job, got %+v", got)
+	}
+
+	pending := m.Create("test", 1)
+	start := time.Now()
+	got, ok = m.Wait(pending.ID, 20*time.Millisecond)
+	if !ok || got.Status != StatusQueued {
+		t.Errorf("expected the queued job after the timeout, got %+v", got)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("expected Wait to block until the timeout")
+	}
+
+	if _, ok := m.Wait("missing", time.Second); ok {
+		t.Error("expected an unknown job to be missing")
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	m := NewManager(time.Minute)
+
+	old := m.Create("test", 1)
+	recent := m.Create("test", 1)
+	running := m.Create("test", 1)
+	m.Finish(old.ID, nil, nil)
+	m.Finish(recent.ID, nil, nil)
+
+	// age the first job past the retention period
+	m.Update(old.ID, func(j *Job) {
+		finished := time.Now().Add(-2 * time.Minute)
+		j.FinishedAt = &finished
+	})
+
+	m.RemoveExpired()
+	if _, ok := m.Get(old.ID); ok {
+		t.Error("expected the expired job to be removed")
+	}
+	for _, id := range []string{recent.ID, running.ID} {
+		if _, ok := m.Get(id); !ok {
+			t.Errorf("expected job %s to be kept", id)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package steamrating
+
+import (
+	"context"
+	"gdrsapi/pkg/logger"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	BatchPending = "pending"
+	BatchOk      = "ok"
+	BatchError   = "error"
+)
+
+// RateAppFunc runs the full rating pipeline for a single app.
+type RateAppFunc func(ctx context.Context, appId string) (*SteamPageRatingResult, error)
+
+type BatchItemResult struct {
+	Input  string                 `json:"input"`
+	AppId  string                 `json:"appId,omitempty"`
+	Url    string                 `json:"url,omitempty"`
+	Status string                 `json:"status"`
+	Result *SteamPageRatingResult `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// BatchRater rates many apps while sharing one concurrency limit and one
+// request rate across every batch that is running.
+type BatchRater struct {
+	logger  *logger.AppLogger
+	rateApp RateAppFunc
+	sem     chan struct{}
+	limiter *rate.Limiter
+}
+
+func NewBatchRater(logger *logger.AppLogger, rateApp RateAppFunc, concurrency int, perMinute int) *BatchRater {
+	return &BatchRater{
+		logger:  logger,
+		rateApp: rateApp,
+		sem:     make(chan struct{}, max(concurrency, 1)),
+		limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(max(perMinute, 1))), 1),
+	}
+}
+
+// NewBatchItems resolves the inputs to app ids. Inputs that are not valid
+// app ids or store urls are marked as failed right away.
+func NewBatchItems(inputs []string) []BatchItemResult {
+	items := make([]BatchItemResult, len(inputs))
+	for i, input := range inputs {
+		items[i] = BatchItemResult{Input: input, Status: BatchPending}
+
+		appId, err := ParseSteamAppId(input)
+		if err != nil {
+			items[i].Status = BatchError
+			items[i].Error = err.Error()
+			continue
+		}
+		items[i].AppId = appId
+		items[i].Url = SteamAppUrl(appId)
+	}
+	return items
+}
+
+// Run rates every pending item and reports each finished item through done.
+// It blocks until the whole batch is processed.
+func (b *BatchRater) Run(ctx context.Context, items []BatchItemResult, done func(i int, item BatchItemResult)) {
+	var wg sync.WaitGroup
+	for i := range items {
+		if items[i].Status != BatchPending {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, item BatchItemResult) {
+			defer wg.Done()
+			b.sem <- struct{}{}
+			defer func() { <-b.sem }()
+
+			if err := b.limiter.Wait(ctx); err != nil {
+				item.Status = BatchError
+				item.Error = err.Error()
+				done(i, item)
+				return
+			}
+
+			b.logger.InfoContext(ctx, "batch rating app", "appId", item.AppId)
+			result, err := b.rateApp(ctx, item.AppId)
+			if err != nil {
+				item.Status = BatchError
+				item.Error = err.Error()
+			} else {
+				item.Status = BatchOk
+				item.Result = result
+			}
+			done(i, item)
+		}(i, items[i])
+	}
+	wg.Wait()
+}
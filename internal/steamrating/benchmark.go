@@ -0,0 +1,324 @@
+package steamrating
+
+import (
+	"context"
+	"fmt"
+	"gdrsapi/external/gsheets"
+	"gdrsapi/pkg/logger"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultCompetitors    = 5
+	maxCompetitors        = 10
+	benchmarkConcurrency  = 3
+	benchmarkSearchTags   = 3
+	comparisonGapFraction = 0.2
+)
+
+type SteamBenchmarker struct {
+	logger  *logger.AppLogger
+	scraper *SteamScraper
+	rater   *SteamRater
+}
+
+func NewSteamBenchmarker(logger *logger.AppLogger, scraper *SteamScraper, rater *SteamRater) *SteamBenchmarker {
+	return &SteamBenchmarker{
+		logger:  logger,
+		scraper: scraper,
+		rater:   rater,
+	}
+}
+
+// BenchmarkRequest selects the page to benchmark and the pages to compare it
+// with. When CompetitorIds is empty, competitors are searched by the target's
+// top tags.
+type BenchmarkRequest struct {
+	AppId          string
+	CompetitorIds  []string
+	MaxCompetitors int
+}
+
+// PageStats are countable properties of a steam page used for comparisons.
+type PageStats struct {
+	Screenshots       int `json:"screenshots"`
+	Tags              int `json:"tags"`
+	Genres            int `json:"genres"`
+	DescriptionLength int `json:"descriptionLength"`
+	AboutWordCount    int `json:"aboutWordCount"`
+	AboutImages       int `json:"aboutImages"`
+	AboutLinks        int `json:"aboutLinks"`
+}
+
+type BenchmarkEntry struct {
+	AppId  string                 `json:"appId"`
+	Url    string                 `json:"url"`
+	Stats  PageStats              `json:"stats"`
+	Rating *SteamPageRatingResult `json:"rating"`
+}
+
+type BenchmarkFailure struct {
+	AppId string `json:"appId"`
+	Error string `json:"error"`
+}
+
+// ComponentBenchmark places a component score among the competitors.
+// Percentile is the share of competitors scoring below the target, ties
+// counting as half.
+type ComponentBenchmark struct {
+	Component         string  `json:"component"`
+	Score             int     `json:"score"`
+	CompetitorAverage float64 `json:"competitorAverage"`
+	Percentile        float64 `json:"percentile"`
+}
+
+type StatComparison struct {
+	Stat              string  `json:"stat"`
+	Value             int     `json:"value"`
+	CompetitorAverage float64 `json:"competitorAverage"`
+	Summary           string  `json:"summary"`
+}
+
+type BenchmarkResult struct {
+	Target      BenchmarkEntry       `json:"target"`
+	Competitors []BenchmarkEntry     `json:"competitors"`
+	Failed      []BenchmarkFailure   `json:"failed,omitempty"`
+	Components  []ComponentBenchmark `json:"components"`
+	Comparisons []StatComparison     `json:"comparisons"`
+}
+
+func (req BenchmarkRequest) competitorLimit() int {
+	limit := req.MaxCompetitors
+	if limit <= 0 {
+		limit = defaultCompetitors
+	}
+	return min(limit, maxCompetitors)
+}
+
+// Ratings is the most pages the benchmark rates, the target included.
+func (req BenchmarkRequest) Ratings() int {
+	limit := req.competitorLimit()
+	if len(req.CompetitorIds) > 0 {
+		limit = min(limit, len(req.CompetitorIds))
+	}
+	return limit + 1
+}
+
+// Benchmark scrapes and rates the target and its competitors with the same
+// rater, then ranks the target against them.
+func (b *SteamBenchmarker) Benchmark(ctx context.Context, req BenchmarkRequest) (*BenchmarkResult, error) {
+	limit := req.competitorLimit()
+
+	target, targetPage, err := b.rateApp(ctx, req.AppId)
+	if err != nil {
+		return nil, fmt.Errorf("rating target app %s: %w", req.AppId, err)
+	}
+
+	competitorIds := req.CompetitorIds
+	if len(competitorIds) == 0 {
+		competitorIds, err = b.findCompetitors(ctx, req.AppId, targetPage.Tags, limit)
+		if err != nil {
+			return nil, fmt.Errorf("finding competitors: %w", err)
+		}
+	}
+	competitorIds = slices.DeleteFunc(slices.Clone(competitorIds), func(id string) bool {
+		return id == req.AppId
+	})
+	if len(competitorIds) > limit {
+		competitorIds = competitorIds[:limit]
+	}
+	if len(competitorIds) == 0 {
+		return nil, fmt.Errorf("no competitors found for app %s", req.AppId)
+	}
+
+	entries := make([]*BenchmarkEntry, len(competitorIds))
+	errs := make([]error, len(competitorIds))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, benchmarkConcurrency)
+	for i, id := range competitorIds {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			entries[i], _, errs[i] = b.rateApp(ctx, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	result := &BenchmarkResult{Target: *target}
+	for i, id := range competitorIds {
+		if errs[i] != nil {
+			b.logger.ErrorContext(ctx, "benchmark competitor failed", "appId", id, "err", errs[i])
+			result.Failed = append(result.Failed, BenchmarkFailure{AppId: id, Error: errs[i].Error()})
+			continue
+		}
+		result.Competitors = append(result.Competitors, *entries[i])
+	}
+
+	if len(result.Competitors) == 0 {
+		return nil, fmt.Errorf("none of the %d competitors could be rated", len(competitorIds))
+	}
+
+	result.Components = compareComponents(result.Target, result.Competitors)
+	result.Comparisons = compareStats(result.Target, result.Competitors)
+	return result, nil
+}
+
+func (b *SteamBenchmarker) rateApp(ctx context.Context, appId string) (*BenchmarkEntry, *SteamPageContent, error) {
+	appUrl := SteamAppUrl(appId)
+
+	spc, err := b.scraper.ScrapeSteamPage(ctx, appUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	se := &gsheets.SheetsEntry{
+		AppId:      appId,
+		Url:        appUrl,
+		PromptType: "benchmark",
+	}
+	rating, err := b.rater.GetSteamPageRating(ctx, *spc, se)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry := &BenchmarkEntry{
+		AppId:  appId,
+		Url:    appUrl,
+		Stats:  GetPageStats(spc),
+		Rating: rating,
+	}
+	return entry, spc, nil
+}
+
+// findCompetitors searches steam for games sharing the target's top tags.
+func (b *SteamBenchmarker) findCompetitors(ctx context.Context, appId string, tags []string, limit int) ([]string, error) {
+	var tagIds []int
+	for _, name := range tags {
+		if tag, ok := Taxonomy().Lookup(name); ok {
+			tagIds = append(tagIds, tag.ID)
+		}
+		if len(tagIds) == benchmarkSearchTags {
+			break
+		}
+	}
+
+	if len(tagIds) == 0 {
+		return nil, fmt.Errorf("none of the app's tags are in the tag taxonomy")
+	}
+
+	// ask for one extra app in case the target shows up in the results
+	appIds, err := b.scraper.SearchAppsByTags(ctx, tagIds, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(appIds, func(id string) bool {
+		return id == appId
+	}), nil
+}
+
+func GetPageStats(spc *SteamPageContent) PageStats {
+	return PageStats{
+		Screenshots:       len(spc.HighlightImgUrls),
+		Tags:              len(spc.Tags),
+		Genres:            len(spc.Genres),
+		DescriptionLength: len(spc.CapsuleDesc),
+		AboutWordCount:    len(strings.Fields(spc.AboutGameText)),
+		AboutImages:       len(spc.AboutGameImgUrls),
+		AboutLinks:        len(spc.AboutGameLinks),
+	}
+}
+
+func compareComponents(target BenchmarkEntry, competitors []BenchmarkEntry) []ComponentBenchmark {
+	overall := ComponentBenchmark{Component: "Overall", Score: target.Rating.FinalWeightedScore}
+	var overallScores []int
+	for _, c := range competitors {
+		overallScores = append(overallScores, c.Rating.FinalWeightedScore)
+	}
+	overall.CompetitorAverage, overall.Percentile = rank(overall.Score, overallScores)
+
+	benchmarks := []ComponentBenchmark{overall}
+	for _, component := range target.Rating.ComponentRatings {
+		var scores []int
+		for _, c := range competitors {
+			for _, cr := range c.Rating.ComponentRatings {
+				if cr.Component == component.Component {
+					scores = append(scores, cr.Score)
+				}
+			}
+		}
+
+		cb := ComponentBenchmark{Component: component.Component, Score: component.Score}
+		cb.CompetitorAverage, cb.Percentile = rank(component.Score, scores)
+		benchmarks = append(benchmarks, cb)
+	}
+	return benchmarks
+}
+
+func rank(value int, others []int) (average float64, percentile float64) {
+	if len(others) == 0 {
+		return 0, 0
+	}
+
+	var sum, below float64
+	for _, o := range others {
+		sum += float64(o)
+		switch {
+		case o < value:
+			below++
+		case o == value:
+			below += 0.5
+		}
+	}
+	return sum / float64(len(others)), below / float64(len(others)) * 100
+}
+
+func compareStats(target BenchmarkEntry, competitors []BenchmarkEntry) []StatComparison {
+	stats := []struct {
+		name  string
+		label string
+		get   func(PageStats) int
+	}{
+		{"screenshots", "screenshots", func(s PageStats) int { return s.Screenshots }},
+		{"tags", "tags", func(s PageStats) int { return s.Tags }},
+		{"genres", "genres", func(s PageStats) int { return s.Genres }},
+		{"descriptionLength", "characters in their short description", func(s PageStats) int { return s.DescriptionLength }},
+		{"aboutWordCount", "words in their about section", func(s PageStats) int { return s.AboutWordCount }},
+		{"aboutImages", "images in their about section", func(s PageStats) int { return s.AboutImages }},
+		{"aboutLinks", "links in their about section", func(s PageStats) int { return s.AboutLinks }},
+	}
+
+	var comparisons []StatComparison
+	for _, stat := range stats {
+		var sum float64
+		for _, c := range competitors {
+			sum += float64(stat.get(c.Stats))
+		}
+		avg := sum / float64(len(competitors))
+		value := stat.get(target.Stats)
+
+		summary := fmt.Sprintf("Competitors average %.1f %s, you have %d.", avg, stat.label, value)
+		switch {
+		case float64(value) < avg*(1-comparisonGapFraction):
+			summary += " You are below the average of similar games."
+		case float64(value) > avg*(1+comparisonGapFraction):
+			summary += " You are above the average of similar games."
+		default:
+			summary += " You are in line with similar games."
+		}
+
+		comparisons = append(comparisons, StatComparison{
+			Stat:              stat.name,
+			Value:             value,
+			CompetitorAverage: avg,
+			Summary:           summary,
+		})
+	}
+	return comparisons
+}
@@ -0,0 +1,57 @@
+package steamrating
+
+import "testing"
+
+func TestRank(t *testing.T) {
+	avg, percentile := rank(60, []int{40, 60, 80, 20})
+	if avg != 50 {
+		t.Errorf("expected average 50, got %v", avg)
+	}
+
+	// two below and one tie out of four
+	if percentile != 62.5 {
+		t.Errorf("expected percentile 62.5, got %v", percentile)
+	}
+}
+
+func TestBenchmarkRatings(t *testing.T) {
+	cases := []struct {
+		req      BenchmarkRequest
+		expected int
+	}{
+		{BenchmarkRequest{AppId: "620"}, defaultCompetitors + 1},
+		{BenchmarkRequest{AppId: "620", MaxCompetitors: 50}, maxCompetitors + 1},
+		{BenchmarkRequest{AppId: "620", CompetitorIds: []string{"400", "220"}}, 3},
+		{BenchmarkRequest{AppId: "620", CompetitorIds: []string{"400", "220"}, MaxCompetitors: 1}, 2},
+	}
+	for _, c := range cases {
+		if got := c.req.Ratings(); got != c.expected {
+			t.Errorf("%+v: expected %d ratings, got %d", c.req, c.expected, got)
+		}
+	}
+}
+
+func TestParseSteamAppId(t *testing.T) {
+	valid := map[string]string{
+		"1840080": "1840080",
+		"https://store.steampowered.com/app/1840080/Parse_O_Rhythm/": "1840080",
+		"https://store.steampowered.com/app/620":                     "620",
+	}
+	for input, expected := range valid {
+		appId, err := ParseSteamAppId(input)
+		if err != nil || appId != expected {
+			t.Errorf("ParseSteamAppId(%q) = %q, %v, expected %q", input, appId, err, expected)
+		}
+	}
+
+	invalid := []string{
+		"", "portal", "-5", "+12", "0", "000", "1e3", "12 34",
+		"https://example.com/app/620/", "https://store.steampowered.com/sub/620/",
+		"https://store.steampowered.com/app/-5/", "https://store.steampowered.com/app/0/",
+	}
+	for _, input := range invalid {
+		if _, err := ParseSteamAppId(input); err == nil {
+			t.Errorf("expected ParseSteamAppId(%q) to fail", input)
+		}
+	}
+}
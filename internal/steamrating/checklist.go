@@ -0,0 +1,265 @@
+package steamrating
+
+import (
+	"encoding/json"
+	"fmt"
+	"gdrsapi/pkg/schema"
+	"strings"
+)
+
+// ChecklistComponent is a section of the steam page that the LLM evaluates
+// against its checklist items.
+type ChecklistComponent struct {
+	ID    string // key of the component in the LLM JSON response
+	Name  string // display name used in the rating result
+	Label string // heading used for the component context in the prompt
+	Note  string // optional guidance appended to the heading
+}
+
+// ChecklistItem is a single yes/no question asked about a component.
+// Weight is relative to the other items of the same component.
+type ChecklistItem struct {
+	ID        string  `json:"id"`
+	Question  string  `json:"question"`
+	Component string  `json:"component"`
+	Weight    float64 `json:"weight"`
+}
+
+// Checklist item statuses reported by the LLM.
+const (
+	StatusPass    = "pass"
+	StatusPartial = "partial"
+	StatusFail    = "fail"
+)
+
+// ChecklistResult is the verdict for a single checklist item. The LLM fills
+// in the status, evidence and suggestion, the question is copied from the
+// checklist definition.
+type ChecklistResult struct {
+	ID         string `json:"id"`
+	Question   string `json:"question,omitempty"`
+	Status     string `json:"status" schema:"enum=pass|partial|fail"`
+	Evidence   string `json:"evidence"`
+	Suggestion string `json:"suggestion"`
+}
+
+var steamPageComponents = []ChecklistComponent{
+	{ID: "description", Name: "Description", Label: "Description"},
+	{ID: "aboutThisGame", Name: "About Game", Label: "AboutThisGame"},
+	{ID: "genres", Name: "Genres", Label: "Genres"},
+	{ID: "highlightImageCaptions", Name: "Highlight Images", Label: "HighlightImage", Note: "image to text descriptions, so be flexible and don't grade it harshly"},
+	{ID: "capsuleImageCaption", Name: "Capsule Image", Label: "CapsuleImage"},
+}
+
+// tagsComponent is rated in Go by RateGameTags, so it is kept out of the
+// components sent to the LLM.
+var tagsComponent = ChecklistComponent{ID: "tags", Name: "Tags"}
+
+// ratingComponentOrder is the order of the components in a rating result.
+var ratingComponentOrder = []string{"description", "tags", "highlightImageCaptions", "genres", "aboutThisGame", "capsuleImageCaption"}
+
+// componentName returns the display name of a component id.
+func componentName(id string) string {
+	if id == tagsComponent.ID {
+		return tagsComponent.Name
+	}
+	for _, c := range steamPageComponents {
+		if c.ID == id {
+			return c.Name
+		}
+	}
+	return id
+}
+
+var steamPageChecklist = []ChecklistItem{
+	{ID: "desc_gameplay_verbs", Component: "description", Weight: 1, Question: "Does it mention gameplay verbs?"},
+	{ID: "desc_hook", Component: "description", Weight: 1, Question: "Does it have a hook?"},
+	{ID: "desc_genre", Component: "description", Weight: 1, Question: "Does it mention at least one game genre?"},
+	{ID: "desc_grammar", Component: "description", Weight: 1, Question: "Is it grammatically correct?"},
+
+	{ID: "about_features", Component: "aboutThisGame", Weight: 1, Question: "Does it mention key features and mechanics?"},
+	{ID: "about_gameplay", Component: "aboutThisGame", Weight: 1, Question: "Does it explain what you do in the game and what the gameplay is like?"},
+	{ID: "about_call_to_action", Component: "aboutThisGame", Weight: 1, Question: "Does it contain a call to action regarding directing players to engage with the game?"},
+	{ID: "about_usp", Component: "aboutThisGame", Weight: 1, Question: "Does it briefly explain the game's core concept or unique selling point?"},
+
+	{ID: "genres_match_description", Component: "genres", Weight: 1, Question: "Do the listed genres align with the game's Description component?"},
+	{ID: "genres_match_about", Component: "genres", Weight: 1, Question: "Do the listed genres align with the game's AboutThisGame component?"},
+	{ID: "genres_established", Component: "genres", Weight: 1, Question: "Do the listed genres include at least one well established game genre?"},
+
+	{ID: "highlight_described", Component: "highlightImageCaptions", Weight: 1, Question: "Are the images context described well?"},
+	{ID: "highlight_concise", Component: "highlightImageCaptions", Weight: 1, Question: "Are the descriptions concise and straight to the point?"},
+	{ID: "highlight_intrigue", Component: "highlightImageCaptions", Weight: 1, Question: "Are there elements in the context that would intrigue potential players?"},
+	{ID: "highlight_mechanics", Component: "highlightImageCaptions", Weight: 1, Question: "Does the context hint at the game's core mechanics or unique features?"},
+	{ID: "highlight_variety", Component: "highlightImageCaptions", Weight: 1, Question: "Do the images context collectively showcase various aspects of the game (e.g., environment, characters, gameplay)?"},
+
+	{ID: "capsule_title", Component: "capsuleImageCaption", Weight: 1, Question: "Does it have the game title in the context text?"},
+	{ID: "capsule_theme", Component: "capsuleImageCaption", Weight: 1, Question: "Does it show a theme or atmosphere in the background?"},
+
+	// tags are checked in Go by RateGameTags and never sent to the LLM
+	{ID: "tags_count", Component: "tags", Weight: 1, Question: fmt.Sprintf("Does the page use between %d and %d tags?", sweetSpotTags, maxTagCount)},
+	{ID: "tags_genre_alignment", Component: "tags", Weight: 2, Question: "Do the tags align with the listed genres?"},
+	{ID: "tags_category_coverage", Component: "tags", Weight: 1, Question: "Do the tags cover genre, sub-genre, theme, feature and visual style?"},
+	{ID: "tags_no_redundancy", Component: "tags", Weight: 0.5, Question: "Does every tag add something not already covered by another tag?"},
+	{ID: "tags_no_contradictions", Component: "tags", Weight: 0.5, Question: "Are the tags free of contradictions?"},
+}
+
+// ChecklistFor returns the checklist items of a component in definition order.
+func ChecklistFor(component string) []ChecklistItem {
+	var items []ChecklistItem
+	for _, item := range steamPageChecklist {
+		if item.Component == component {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ScoreChecklist computes a 0-100 score for a component from the LLM results.
+// Partial items earn half their weight, items missing from the results count
+// as failed and unknown ids are ignored.
+func ScoreChecklist(component string, results []ChecklistResult) float64 {
+	statuses := make(map[string]string, len(results))
+	for _, r := range results {
+		statuses[r.ID] = r.Status
+	}
+
+	var total, earned float64
+	for _, item := range ChecklistFor(component) {
+		total += item.Weight
+		switch statuses[item.ID] {
+		case StatusPass:
+			earned += item.Weight
+		case StatusPartial:
+			earned += item.Weight / 2
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return earned / total * 100
+}
+
+// ResolveChecklist returns one result per checklist item of the component in
+// definition order, with the question filled in. Items the LLM skipped are
+// reported as failed.
+func ResolveChecklist(component string, results []ChecklistResult) []ChecklistResult {
+	byId := make(map[string]ChecklistResult, len(results))
+	for _, r := range results {
+		byId[r.ID] = r
+	}
+
+	items := ChecklistFor(component)
+	resolved := make([]ChecklistResult, 0, len(items))
+	for _, item := range items {
+		r, ok := byId[item.ID]
+		if !ok {
+			r = ChecklistResult{ID: item.ID, Status: StatusFail}
+		}
+		r.Question = item.Question
+		resolved = append(resolved, r)
+	}
+	return resolved
+}
+
+// RatingResponseSchema describes the JSON the LLM must answer with. It is
+// generated from LLMInnerResponse and narrowed so that every component reports
+// each of its checklist items exactly once.
+func RatingResponseSchema() *schema.Schema {
+	s := schema.For(LLMInnerResponse{})
+	for _, c := range steamPageComponents {
+		items := ChecklistFor(c.ID)
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+
+		checklist := s.Properties[c.ID].Properties["checklist"]
+		checklist.WithItems(len(items), len(items))
+		checklist.Items.Properties["id"].Enum = ids
+	}
+	return s
+}
+
+// ParseRatingResponse validates the raw LLM reply and decodes it.
+func ParseRatingResponse(respBytes []byte) (*LLMInnerResponse, error) {
+	if err := RatingResponseSchema().Validate(respBytes); err != nil {
+		return nil, err
+	}
+
+	rating := &LLMInnerResponse{}
+	if err := json.Unmarshal(respBytes, rating); err != nil {
+		return nil, fmt.Errorf("decode rating response: %w", err)
+	}
+
+	// the schema checks the amount of items, make sure none are repeated
+	var problems []string
+	for _, c := range steamPageComponents {
+		seen := make(map[string]bool)
+		for _, r := range rating.evaluation(c.ID).Checklist {
+			if seen[r.ID] {
+				problems = append(problems, fmt.Sprintf("$.%s.checklist: item %q is reported more than once", c.ID, r.ID))
+			}
+			seen[r.ID] = true
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &schema.ValidationError{Problems: problems}
+	}
+
+	return rating, nil
+}
+
+// componentContext returns the scraped content the LLM evaluates for a component.
+func (ctx *SteamPagePromptCtx) componentContext(component string) string {
+	switch component {
+	case "description":
+		return ctx.Description
+	case "aboutThisGame":
+		return ctx.AboutThisGame
+	case "genres":
+		return "Genres: " + strings.Join(ctx.Genres, ", ")
+	case "highlightImageCaptions":
+		return strings.Join(ctx.HighlightImageCaptions, ",\n")
+	case "capsuleImageCaption":
+		return ctx.CapsuleImageCaption
+	}
+	return ""
+}
+
+func writeChecklist(b *strings.Builder, component string, indent string) {
+	b.WriteString(indent + "Checklist:\n")
+	for _, item := range ChecklistFor(component) {
+		fmt.Fprintf(b, "%s- [%s] %s\n", indent, item.ID, item.Question)
+	}
+}
+
+// checklistOutputFormat builds the JSON layout the LLM has to answer with.
+func checklistOutputFormat(indent string) string {
+	var b strings.Builder
+	b.WriteString(indent + "{\n")
+	for i, c := range steamPageComponents {
+		fmt.Fprintf(&b, "%s\t%q: {\n", indent, c.ID)
+		fmt.Fprintf(&b, "%s\t\t\"checklist\": [\n", indent)
+		items := ChecklistFor(c.ID)
+		for j, item := range items {
+			sep := ","
+			if j == len(items)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&b, "%s\t\t\t{\"id\": %q, \"status\": \"\", \"evidence\": \"\", \"suggestion\": \"\"}%s\n", indent, item.ID, sep)
+		}
+		fmt.Fprintf(&b, "%s\t\t],\n", indent)
+		fmt.Fprintf(&b, "%s\t\t\"score\": 0,\n", indent)
+		fmt.Fprintf(&b, "%s\t\t\"actionablefeedback\": \"\",\n", indent)
+		fmt.Fprintf(&b, "%s\t\t\"strengths\": \"\"\n", indent)
+
+		sep := ","
+		if i == len(steamPageComponents)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&b, "%s\t}%s\n", indent, sep)
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
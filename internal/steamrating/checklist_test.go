@@ -0,0 +1,156 @@
+package steamrating
+
+import (
+	"encoding/json"
+	"errors"
+	"gdrsapi/pkg/schema"
+	"strings"
+	"testing"
+)
+
+func TestScoreChecklist(t *testing.T) {
+	results := []ChecklistResult{
+		{ID: "desc_gameplay_verbs", Status: StatusPass},
+		{ID: "desc_hook", Status: StatusPartial},
+		{ID: "desc_genre", Status: StatusFail},
+		{ID: "unknown_item", Status: StatusPass},
+	}
+
+	// desc_grammar is missing and counts as failed
+	score := ScoreChecklist("description", results)
+	if score != 37.5 {
+		t.Errorf("expected score 37.5, got %v", score)
+	}
+
+	if score := ScoreChecklist("description", nil); score != 0 {
+		t.Errorf("expected score 0 without results, got %v", score)
+	}
+}
+
+func TestResolveChecklist(t *testing.T) {
+	results := []ChecklistResult{
+		{ID: "capsule_theme", Status: StatusPass, Evidence: "a dark forest"},
+	}
+
+	resolved := ResolveChecklist("capsuleImageCaption", results)
+	if len(resolved) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(resolved))
+	}
+
+	if resolved[0].ID != "capsule_title" || resolved[0].Status != StatusFail {
+		t.Errorf("expected missing capsule_title to fail, got %+v", resolved[0])
+	}
+
+	if resolved[1].Question == "" || resolved[1].Evidence != "a dark forest" {
+		t.Errorf("expected capsule_theme to keep evidence and get a question, got %+v", resolved[1])
+	}
+}
+
+func TestGetSteamPageEvalPrompt(t *testing.T) {
+	ctx := &SteamPagePromptCtx{
+		Description:            "A cozy farming game",
+		AboutThisGame:          "Grow crops and befriend villagers",
+		Genres:                 []string{"Simulation", "Indie"},
+		HighlightImageCaptions: []string{"A field of crops"},
+		CapsuleImageCaption:    "The title Farm Days over a sunset",
+	}
+
+	prompt := GetSteamPageEvalPrompt(ctx)
+	for _, c := range steamPageComponents {
+		for _, item := range ChecklistFor(c.ID) {
+			if !strings.Contains(prompt, "["+item.ID+"] "+item.Question) {
+				t.Errorf("prompt is missing checklist item %s", item.ID)
+			}
+		}
+	}
+
+	if strings.Contains(prompt, "tags_count") {
+		t.Errorf("prompt should not contain the tags checklist")
+	}
+
+	if strings.Contains(prompt, "%!") {
+		t.Errorf("prompt contains formatting errors:\n%s", prompt)
+	}
+}
+
+func validRatingResponse() map[string]interface{} {
+	resp := map[string]interface{}{}
+	for _, c := range steamPageComponents {
+		var checklist []map[string]string
+		for _, item := range ChecklistFor(c.ID) {
+			checklist = append(checklist, map[string]string{
+				"id":         item.ID,
+				"status":     StatusPass,
+				"evidence":   "",
+				"suggestion": "",
+			})
+		}
+		resp[c.ID] = map[string]interface{}{
+			"checklist":          checklist,
+			"score":              80,
+			"actionablefeedback": "",
+			"strengths":          "",
+		}
+	}
+	return resp
+}
+
+func TestParseRatingResponse(t *testing.T) {
+	resp := validRatingResponse()
+	data, _ := json.Marshal(resp)
+
+	rating, err := ParseRatingResponse(data)
+	if err != nil {
+		t.Fatalf("expected valid response, got %v", err)
+	}
+
+	if score := ScoreChecklist("description", rating.Description.Checklist); score != 100 {
+		t.Errorf("expected description score 100, got %v", score)
+	}
+
+	// repeat the first capsule item instead of reporting the second one
+	capsule := resp["capsuleImageCaption"].(map[string]interface{})
+	checklist := capsule["checklist"].([]map[string]string)
+	checklist[1] = checklist[0]
+	data, _ = json.Marshal(resp)
+
+	var vErr *schema.ValidationError
+	if _, err := ParseRatingResponse(data); !errors.As(err, &vErr) {
+		t.Errorf("expected a validation error for duplicated items, got %v", err)
+	}
+
+	delete(resp, "genres")
+	data, _ = json.Marshal(resp)
+	if _, err := ParseRatingResponse(data); err == nil {
+		t.Errorf("expected missing components to fail validation")
+	}
+}
+
+func TestParseRatingResponseScoreRange(t *testing.T) {
+	for _, score := range []float64{250, -3, 72.5} {
+		resp := validRatingResponse()
+		resp["genres"].(map[string]interface{})["score"] = score
+		data, _ := json.Marshal(resp)
+
+		var vErr *schema.ValidationError
+		if _, err := ParseRatingResponse(data); !errors.As(err, &vErr) || !strings.Contains(err.Error(), "$.genres.score") {
+			t.Errorf("expected a score of %v to fail validation, got %v", score, err)
+		}
+	}
+
+	resp := validRatingResponse()
+	resp["genres"].(map[string]interface{})["score"] = 0
+	data, _ := json.Marshal(resp)
+	rating, err := ParseRatingResponse(data)
+	if err != nil || rating.Genres.Score != 0 {
+		t.Errorf("expected a score of 0 to be valid, got %v", err)
+	}
+}
+
+func TestComponentNames(t *testing.T) {
+	expected := []string{"Description", "Tags", "Highlight Images", "Genres", "About Game", "Capsule Image"}
+	names := ComponentNames()
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,78 @@
+package steamrating
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gdrsapi/internal/usage"
+	"gdrsapi/pkg/store"
+	"time"
+)
+
+const ratingsCollection = "ratings"
+
+var ErrRatingNotFound = errors.New("rating not found")
+
+// RatingRecord is a finished rating kept so it can be looked up and shared
+// after the request that produced it.
+type RatingRecord struct {
+	ID        string                 `json:"id"`
+	AppId     string                 `json:"appId"`
+	Url       string                 `json:"url"`
+	Title     string                 `json:"title"`
+	CreatedAt time.Time              `json:"createdAt"`
+	Rating    *SteamPageRatingResult `json:"rating"`
+	// Usage is what producing the rating cost in tokens and dollars
+	Usage *usage.Totals `json:"usage,omitempty"`
+}
+
+type RatingHistory struct {
+	store *store.Store
+}
+
+func NewRatingHistory(store *store.Store) *RatingHistory {
+	return &RatingHistory{store: store}
+}
+
+// Save stores the rating and what it cost under a new id. The id is set on
+// the rating once it is stored, a rating that could not be kept has none.
+func (h *RatingHistory) Save(appId string, url string, title string, rating *SteamPageRatingResult, spent usage.Totals) (*RatingRecord, error) {
+	stored := *rating
+	record := &RatingRecord{
+		ID:        newRatingId(),
+		AppId:     appId,
+		Url:       url,
+		Title:     title,
+		CreatedAt: time.Now().UTC(),
+		Rating:    &stored,
+		Usage:     &spent,
+	}
+	stored.Id = record.ID
+
+	if err := h.store.Put(ratingsCollection, record.ID, record); err != nil {
+		return nil, fmt.Errorf("saving rating: %w", err)
+	}
+	rating.Id = record.ID
+	return record, nil
+}
+
+func (h *RatingHistory) Get(id string) (*RatingRecord, error) {
+	var record RatingRecord
+	err := h.store.Get(ratingsCollection, id, &record)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrRatingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func newRatingId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating rating id: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,51 @@
+package steamrating
+
+import (
+	"gdrsapi/internal/usage"
+	"gdrsapi/pkg/store"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRatingHistorySave(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := NewRatingHistory(s)
+
+	rating := &SteamPageRatingResult{FinalWeightedScore: 72}
+	record, err := history.Save("620", SteamAppUrl("620"), "Portal 2", rating, usage.Totals{Calls: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rating.Id == "" || rating.Id != record.ID {
+		t.Errorf("expected the rating to get the record id %s, got %q", record.ID, rating.Id)
+	}
+
+	stored, err := history.Get(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Rating.Id != record.ID || stored.Rating.FinalWeightedScore != 72 {
+		t.Errorf("unexpected stored rating %+v", stored.Rating)
+	}
+
+	// a file in place of the collection dir makes every save fail
+	if err := os.RemoveAll(filepath.Join(dir, ratingsCollection)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ratingsCollection), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	unsaved := &SteamPageRatingResult{FinalWeightedScore: 40}
+	if _, err := history.Save("400", SteamAppUrl("400"), "Portal", unsaved, usage.Totals{}); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+	if unsaved.Id != "" {
+		t.Errorf("expected a rating that was not kept to have no id, got %q", unsaved.Id)
+	}
+}
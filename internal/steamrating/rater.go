@@ -1,169 +1,36 @@
 package steamrating
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"gdrsapi/external/cloudflare"
 	"gdrsapi/external/gemini"
 	"gdrsapi/external/gsheets"
+	"gdrsapi/internal/usage"
 	"gdrsapi/pkg/logger"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
-var genreToTags = map[string][]string{
-	"action": {
-		"action", "hack and slash", "hack-and-slash", "beat 'em up", "brawler",
-		"fighting", "martial arts", "third-person", "melee combat", "spectacle fighter",
-		"character action game", "sword fighting", "gun fu", "bullet time", "combo-based",
-		"fast-paced", "reflex-based", "hand-to-hand combat", "weapon-based fighter",
-		"arena combat", "action-adventure", "parkour", "quick time events", "qte",
-		"cinematic action", "stealth action", "assassin", "ninja", "samurai",
-	},
-	"adventure": {
-		"adventure", "exploration", "story-rich", "point-and-click", "narrative",
-		"choice matter", "interactive fiction", "text-based", "walking simulator",
-		"visual novel", "puzzle-adventure", "hidden object", "escape room", "mystery",
-		"detective", "thriller", "horror adventure", "survival horror", "psychological horror",
-		"adventure rpg", "action-adventure", "open world adventure", "historical adventure",
-		"sci-fi adventure", "fantasy adventure", "episodic", "story-driven", "branching narrative",
-		"multiple endings", "time travel", "archaeology", "treasure hunting",
-	},
-	"strategy": {
-		"strategy", "turn-based", "turn based", "real-time strategy", "rts", "4x",
-		"grand strategy", "tower defense", "auto battler", "tactical", "wargame",
-		"card game", "deck-building", "moba", "base-building", "city-builder",
-		"resource management", "economy", "diplomacy", "political", "historical",
-		"military", "battle simulator", "tactics", "squad-based tactics", "hero collector",
-		"multiplayer online battle arena", "tower offense", "defense", "automation",
-		"programming", "hacking", "cyberpunk", "space strategy", "naval", "trading",
-	},
-	"rpg": {
-		"rpg", "role playing", "role-playing", "action rpg", "jrpg", "crpg",
-		"party-based", "turn-based rpg", "open world", "character customization",
-		"dungeon crawler", "building", "farming", "western rpg", "sandbox rpg",
-		"tactical rpg", "roguelike rpg", "action-adventure rpg", "mmorpg", "online rpg",
-		"story-rich rpg", "choice matter", "multiple endings", "class-based",
-		"skill tree", "leveling system", "loot-based", "crafting", "alchemy",
-		"magic system", "fantasy rpg", "sci-fi rpg", "post-apocalyptic rpg",
-		"cyberpunk rpg", "steampunk rpg", "historical rpg", "medieval rpg",
-	},
-	"simulation": {
-		"simulation", "life sim", "farm sim", "management", "tycoon", "business sim",
-		"dating sim", "social sim", "space sim", "flight sim", "train sim", "truck sim",
-		"cooking sim", "city-builder", "pet sim", "animal sim", "medical sim", "surgery sim",
-		"sports management", "political sim", "war sim", "ecosystem sim", "physics sim",
-		"vehicle sim", "driving sim", "racing sim", "sailing sim", "submarine sim",
-		"economy sim", "government sim", "colony sim", "survival sim", "crafting sim",
-		"building sim", "automation sim", "factory sim", "agriculture sim", "biology sim",
-	},
-	"sports": {
-		"sports", "football", "soccer", "basketball", "baseball", "golf", "tennis",
-		"wrestling", "extreme sports", "team sports", "sports management", "olympics",
-		"hockey", "ice hockey", "volleyball", "beach volleyball", "cricket", "rugby",
-		"american football", "boxing", "mma", "martial arts", "skateboarding", "snowboarding",
-		"skiing", "surfing", "bmx", "cycling", "athletics", "track and field", "swimming",
-		"diving", "gymnastics", "billiards", "pool", "snooker", "darts", "bowling",
-		"table tennis", "ping pong", "badminton", "lacrosse", "water sports",
-	},
-	"racing": {
-		"racing", "car racing", "motorcycle racing", "offroad", "racing sim",
-		"kart racing", "arcade racing", "rally", "drag racing", "motocross",
-		"formula racing", "stock car racing", "street racing", "futuristic racing",
-		"bike racing", "boat racing", "jet ski racing", "hovercraft racing", "racing management",
-		"time attack", "drift racing", "demolition derby", "truck racing", "buggy racing",
-		"atv racing", "snowmobile racing", "racing rpg", "open world racing", "racing strategy",
-	},
-	"puzzle": {
-		"puzzle", "logic", "physics puzzle", "match-3", "hidden object", "escape room",
-		"jigsaw puzzle", "sudoku", "word game", "programming puzzle", "block-pushing puzzle",
-		"sliding puzzle", "pattern recognition", "memory puzzle", "math puzzle", "riddle",
-		"maze", "sokoban", "bridge-building", "contraption-builder", "puzzle platformer",
-		"puzzle-adventure", "casual puzzle", "bubble shooter", "tile-matching", "tangram",
-		"crossword", "logic grid", "picross", "nonogram", "cryptogram", "anagram",
-		"spatial reasoning", "color matching", "connect the dots", "pipe connecting",
-	},
-	"arcade": {
-		"arcade", "retro", "classic", "score attack", "endless runner", "rhythm",
-		"music game", "pinball", "breakout", "shoot 'em up", "bullet hell", "side-scroller",
-		"beat 'em up", "fighting game", "light gun", "rail shooter", "maze game", "platformer",
-		"twin-stick shooter", "fixed shooter", "puzzle bobble", "tetris-like", "pong-like",
-		"pac-man-like", "space invaders-like", "galaga-like", "donkey kong-like", "frogger-like",
-		"centipede-like", "asteroids-like", "defender-like", "joust-like", "qbert-like",
-		"dig dug-like", "bubble bobble-like", "rampage-like", "gauntlet-like",
-	},
-	"platformer": {
-		"platformer", "2D platformer", "3D platformer", "metroidvania", "run and gun",
-		"precision platformer", "puzzle platformer", "action platformer", "cinematic platformer",
-		"physics-based platformer", "endless platformer", "roguelike platformer", "auto-runner",
-		"side-scroller", "exploration platformer", "collectathon", "mascot platformer",
-		"parkour platformer", "stealth platformer", "speedrun platformer", "hardcore platformer",
-		"platformer shooter", "platformer rpg", "co-op platformer", "competitive platformer",
-		"wall-jumping", "double-jump", "grappling hook", "swinging mechanics",
-	},
-	"shooter": {
-		"shooter", "fps", "first-person shooter", "third-person shooter", "shmup", "shoot 'em up",
-		"bullet hell", "tactical shooter", "arena shooter", "on-rails shooter", "battle royale",
-		"hero shooter", "looter shooter", "cover shooter", "team-based shooter", "class-based shooter",
-		"mil-sim", "arcade shooter", "vehicular combat", "space shooter", "zombie shooter",
-		"survival shooter", "co-op shooter", "twin-stick shooter", "top-down shooter",
-		"isometric shooter", "stealth shooter", "time-manipulation shooter", "retro shooter",
-		"physics-based shooter", "sci-fi shooter", "realistic shooter", "western shooter",
-	},
-	"visual novel": {
-		"visual novel", "otome", "kinetic novel", "dating sim", "choice matter", "multiple endings",
-		"romance", "interactive fiction", "text-based", "story-rich", "branching narrative",
-		"character-driven", "dialogue-heavy", "slice of life", "mystery visual novel",
-		"horror visual novel", "sci-fi visual novel", "fantasy visual novel", "historical visual novel",
-		"psychological", "drama", "comedy", "thriller", "supernatural", "school life", "coming of age",
-		"adult", "all-ages", "boys' love", "girls' love", "harem", "reverse harem", "episodic",
-	},
-	"tabletop": {
-		"tabletop", "board game", "card game", "dice", "chess", "gambling", "tabletop rpg",
-		"collectible card game", "deck-building", "miniatures", "tile-placement", "worker placement",
-		"area control", "strategy board game", "party game", "social deduction", "hidden role",
-		"cooperative board game", "legacy board game", "eurogame", "ameritrash", "abstract strategy",
-		"wargame", "roll and write", "auction", "drafting", "push your luck", "real-time",
-		"dexterity", "memory", "word game", "trivia", "escape room game", "dungeon crawler",
-	},
-	"roguelike": {
-		"roguelike", "roguelite", "rogue-like", "rogue-lite", "procedural generation", "permadeath",
-		"dungeon crawler", "run-based", "randomized", "character progression", "meta-progression",
-		"replayability", "turn-based roguelike", "real-time roguelike", "action roguelike",
-		"strategy roguelike", "rpg roguelike", "shooter roguelike", "platformer roguelike",
-		"card roguelike", "survival roguelike", "mystery dungeon", "traditional roguelike",
-		"coffee break roguelike", "ascii roguelike", "tactical roguelike", "deck-building roguelike",
-		"roguelike-metroidvania", "bullet hell roguelike",
-	},
-	"sandbox": {
-		"sandbox", "open world", "crafting", "building", "voxel", "physics", "creative",
-		"exploration", "survival", "procedural generation", "terraforming", "base-building",
-		"resource management", "life simulation", "social simulation", "player-driven economy",
-		"player-created content", "mod support", "multiplayer sandbox", "virtual world", "space sandbox",
-		"historical sandbox", "fantasy sandbox", "sci-fi sandbox", "post-apocalyptic sandbox",
-		"crime sandbox", "medieval sandbox", "western sandbox", "underwater sandbox", "playground",
-		"simulation sandbox", "sandbox rpg",
-	},
-	"education": {
-		"education", "educational", "learning", "science", "math", "language learning", "history",
-		"geography", "programming", "typing", "quiz", "puzzle", "brain training", "memory", "logic",
-		"problem-solving", "critical thinking", "creativity", "art", "music education",
-		"physics simulation", "chemistry", "biology", "anatomy", "astronomy", "geology", "environmental",
-		"social studies", "economics", "political science", "psychology", "philosophy", "literature",
-		"grammar", "vocabulary", "foreign language", "sign language", "coding for kids",
-	},
-	"indie": {
-		"indie", "experimental", "artistic", "minimalist", "pixel graphics", "hand-drawn", "stylized",
-		"atmospheric", "surreal", "abstract", "quirky", "unique", "innovative", "niche", "cult classic",
-		"short", "casual", "story-rich", "emotional", "thought-provoking", "philosophical", "political",
-		"social commentary", "indie rpg", "indie platformer", "indie puzzle", "indie adventure",
-		"indie horror", "indie strategy", "indie simulation", "indie roguelike", "indie multiplayer",
-		"indie co-op", "indie sandbox",
-	}}
+const (
+	// highlightImgs is how many highlight images are captioned per page
+	highlightImgs = 3
+
+	// CallsPerRating is the model calls one rating makes: a caption for each
+	// highlight image and the capsule, then the rating itself.
+	CallsPerRating = highlightImgs + 2
+)
+
+// imgClient downloads page images, a stalled cdn must not hold up the rating.
+var imgClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
 
 type SteamRater struct {
 	logger    *logger.AppLogger
@@ -189,9 +56,15 @@ func NewSteamRater(logger *logger.AppLogger) *SteamRater {
 	}
 }
 
-func (s *SteamRater) GetSteamPageRating(spc SteamPageContent, se *gsheets.SheetsEntry) (*SteamPageRatingResult, error) {
-	const scoreMult = 20
-	var imgUrlContextList = s.ExtractImgUrlsGenerateText(&spc)
+// GetSteamPageRating rates the page. The calls it makes are attributed to the
+// entry's app id, and no rating starts once the daily spend limit is reached.
+func (s *SteamRater) GetSteamPageRating(ctx context.Context, spc SteamPageContent, se *gsheets.SheetsEntry) (*SteamPageRatingResult, error) {
+	if err := usage.CheckBudget(ctx); err != nil {
+		return nil, err
+	}
+	ctx = usage.WithAttribution(ctx, usage.Attribution{AppId: se.AppId})
+
+	var imgUrlContextList = s.ExtractImgUrlsGenerateText(ctx, &spc)
 
 	spPromptContext := &SteamPagePromptCtx{
 		Description:   spc.CapsuleDesc,
@@ -200,41 +73,28 @@ func (s *SteamRater) GetSteamPageRating(spc SteamPageContent, se *gsheets.Sheets
 	}
 	err := AddImgCaptionToCtx(spPromptContext, imgUrlContextList)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
+		s.logger.ErrorContext(ctx, "adding image captions", "err", err)
 		return nil, err
 	}
 
 	finalPrompt := GetSteamPageEvalPrompt(spPromptContext)
-	s.logger.InfoLog.Println("finished final prompt")
-	s.logger.InfoLog.Println(finalPrompt)
+	s.logger.DebugContext(ctx, "finished final prompt", logger.Sensitive("prompt", finalPrompt))
 
-	respBytes, err := s.geminiSvc.CallGeminiLLMApi(finalPrompt)
-	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
-		return nil, err
-	}
-	rating := &LLMInnerResponse{}
-	err = json.Unmarshal(respBytes, rating)
+	rating, err := s.requestRating(ctx, finalPrompt)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
+		s.logger.ErrorContext(ctx, "requesting rating", "err", err)
 		return nil, err
 	}
-	s.logger.InfoLog.Println("finished generating gemini rating response")
+	s.logger.InfoContext(ctx, "finished generating gemini rating response")
 
-	spscr := RateGameTags(spc.Genres, spc.Tags)
+	spscr, tagAnalysis := RateGameTags(spc.Genres, spc.Tags)
 
-	descriptionScore, _ := strconv.ParseFloat(rating.Description.Score, 64)
-	genresSectionScore, _ := strconv.ParseFloat(rating.Genres.Score, 64)
-	tagsScore := int(spscr.Score[0])
-	highlightImagesScore, _ := strconv.ParseFloat(rating.HighlightImageCaptions.Score, 64)
-	aboutSectionScore, _ := strconv.ParseFloat(rating.AboutThisGame.Score, 64)
-	capsuleImageScore, _ := strconv.ParseFloat(rating.CapsuleImageCaption.Score, 64)
-	descriptionScore *= scoreMult
-	genresSectionScore *= scoreMult
-	tagsScoreF := float64(tagsScore) * scoreMult
-	highlightImagesScore *= scoreMult
-	aboutSectionScore *= scoreMult
-	capsuleImageScore *= scoreMult
+	descriptionScore := ScoreChecklist("description", rating.Description.Checklist)
+	genresSectionScore := ScoreChecklist("genres", rating.Genres.Checklist)
+	tagsScore := float64(spscr.Score)
+	highlightImagesScore := ScoreChecklist("highlightImageCaptions", rating.HighlightImageCaptions.Checklist)
+	aboutSectionScore := ScoreChecklist("aboutThisGame", rating.AboutThisGame.Checklist)
+	capsuleImageScore := ScoreChecklist("capsuleImageCaption", rating.CapsuleImageCaption.Checklist)
 
 	// Define weights
 	const (
@@ -249,7 +109,7 @@ func (s *SteamRater) GetSteamPageRating(spc SteamPageContent, se *gsheets.Sheets
 	// Calculate weighted scores
 	weightedDescriptionScore := descriptionScore * descriptionWeight
 	weightedGenresScore := genresSectionScore * genresWeight
-	weightedTagsScore := tagsScoreF * tagsWeight
+	weightedTagsScore := tagsScore * tagsWeight
 	weightedHighlightImagesScore := highlightImagesScore * highlightImagesWeight
 	weightedAboutSectionScore := aboutSectionScore * aboutSectionWeight
 	weightedCapsuleImageScore := capsuleImageScore * capsuleImageWeight
@@ -257,30 +117,14 @@ func (s *SteamRater) GetSteamPageRating(spc SteamPageContent, se *gsheets.Sheets
 	totalWeightedScore := int(weightedDescriptionScore + weightedGenresScore + weightedTagsScore +
 		weightedHighlightImagesScore + weightedAboutSectionScore + weightedCapsuleImageScore)
 
-	// Set component names
-	spscr.Component = "Tags"
-	rating.Description.Component = "Description"
-	rating.Genres.Component = "Genres"
-	rating.HighlightImageCaptions.Component = "Highlight Images"
-	rating.AboutThisGame.Component = "About Game"
-	rating.CapsuleImageCaption.Component = "Capsule Image"
-
-	// Update scores as strings
-	rating.Description.Score = strconv.Itoa(int(descriptionScore))
-	rating.Genres.Score = strconv.Itoa(int(genresSectionScore))
-	spscr.Score = strconv.Itoa(int(tagsScoreF))
-	rating.HighlightImageCaptions.Score = strconv.Itoa(int(highlightImagesScore))
-	rating.AboutThisGame.Score = strconv.Itoa(int(aboutSectionScore))
-	rating.CapsuleImageCaption.Score = strconv.Itoa(int(capsuleImageScore))
-
 	// Create components slice
 	steamPageComponentRatings := []SteamPageSingleComponentRating{
-		rating.Description,
+		rating.Description.toRating("description", descriptionScore),
 		*spscr,
-		rating.HighlightImageCaptions,
-		rating.Genres,
-		rating.AboutThisGame,
-		rating.CapsuleImageCaption,
+		rating.HighlightImageCaptions.toRating("highlightImageCaptions", highlightImagesScore),
+		rating.Genres.toRating("genres", genresSectionScore),
+		rating.AboutThisGame.toRating("aboutThisGame", aboutSectionScore),
+		rating.CapsuleImageCaption.toRating("capsuleImageCaption", capsuleImageScore),
 	}
 
 	// Create combined response
@@ -288,6 +132,7 @@ func (s *SteamRater) GetSteamPageRating(spc SteamPageContent, se *gsheets.Sheets
 		FinalWeightedScore: totalWeightedScore,
 		CapsuleUrl:         spc.CapsuleImgUrl,
 		ComponentRatings:   steamPageComponentRatings,
+		TagAnalysis:        tagAnalysis,
 	}
 
 	ratingData, _ := json.Marshal(steamPageRatingResult)
@@ -300,172 +145,223 @@ func (s *SteamRater) GetSteamPageRating(spc SteamPageContent, se *gsheets.Sheets
 	return steamPageRatingResult, nil
 }
 
-func RateGameTags(genres []string, tags []string) *SteamPageSingleComponentRating {
-	var runningTotal int = 0
+// requestRating asks the LLM for a rating and re-asks with the validation
+// errors when the reply does not match the expected schema.
+func (s *SteamRater) requestRating(ctx context.Context, prompt string) (*LLMInnerResponse, error) {
+	const maxAttempts = 2
+
+	req := gemini.NewRequest().
+		System(raterSystemInstruction).
+		User(prompt).
+		Schema(RatingResponseSchema())
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		respBytes, err := s.geminiSvc.Send(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		rating, err := ParseRatingResponse(respBytes)
+		if err == nil {
+			return rating, nil
+		}
+
+		s.logger.ErrorContext(ctx, "invalid rating response", "attempt", attempt, "err", err)
+		lastErr = err
+		// keep the rejected answer in the conversation and ask for a fix
+		req.Model(string(respBytes)).User(GetRatingRepairPrompt(err))
+	}
+
+	return nil, fmt.Errorf("llm returned an invalid rating after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// ComponentNames lists the rated components in the order they appear in a
+// rating result.
+func ComponentNames() []string {
+	names := make([]string, 0, len(ratingComponentOrder))
+	for _, id := range ratingComponentOrder {
+		names = append(names, componentName(id))
+	}
+	return names
+}
+
+func RateGameTags(genres []string, tags []string) (*SteamPageSingleComponentRating, *TagAnalysis) {
 	var tagsNegFeedback []string
 	var tagsPosFeedback []string
 
-	var targetTags []string
-	for _, genre := range genres {
-		lGen := strings.ToLower(genre)
-		if _, ok := genreToTags[lGen]; ok {
-			lTags := genreToTags[lGen]
-			targetTags = append(targetTags, lTags...)
+	analysis := Taxonomy().AnalyzeTags(genres, tags)
+
+	countResult := ChecklistResult{ID: "tags_count", Evidence: fmt.Sprintf("%d tags", len(tags))}
+	switch {
+	case analysis.InSweetSpot:
+		countResult.Status = StatusPass
+		tagsPosFeedback = append(tagsPosFeedback, fmt.Sprintf("You have %d tags, which is right in the %d-%d sweet spot for search visibility.", len(tags), sweetSpotTags, maxTagCount))
+	case len(tags) >= minTagCount && len(tags) < sweetSpotTags:
+		countResult.Status = StatusPartial
+		countResult.Suggestion = fmt.Sprintf("You have %d tags. Consider using all %d tag slots, pages with %d-%d tags get the best search visibility.", len(tags), maxTagCount, sweetSpotTags, maxTagCount)
+	case len(tags) > maxTagCount:
+		countResult.Status = StatusPartial
+		countResult.Suggestion = fmt.Sprintf("You have %d tags. Only the top %d tags are shown on your page, focus on the ones that describe your game best.", len(tags), maxTagCount)
+	default:
+		countResult.Status = StatusFail
+		countResult.Suggestion = fmt.Sprintf("You only have %d tags. Add more tags, you should have anywhere from %d-%d tags for optimal visibility results.", len(tags), sweetSpotTags, maxTagCount)
+	}
+
+	alignResult := ChecklistResult{ID: "tags_genre_alignment", Evidence: strings.Join(analysis.GenreAlignedTags, ", ")}
+	switch aligned := len(analysis.GenreAlignedTags); {
+	case aligned >= 5:
+		alignResult.Status = StatusPass
+		tagsPosFeedback = append(tagsPosFeedback, "Your tags seem to align with your genre.")
+	case aligned >= 2:
+		alignResult.Status = StatusPartial
+		alignResult.Suggestion = "Consider adding more tags that align with your genre."
+	default:
+		alignResult.Status = StatusFail
+		alignResult.Suggestion = "Very few of your tags match your genres. Add tags that describe the genres your game is listed under."
+	}
+
+	coverageResult := ChecklistResult{ID: "tags_category_coverage"}
+	var coveredCategories []string
+	for _, category := range Taxonomy().Categories {
+		if len(analysis.Categories[category]) > 0 {
+			coveredCategories = append(coveredCategories, category)
 		}
 	}
+	coverageResult.Evidence = strings.Join(coveredCategories, ", ")
+	switch missing := len(analysis.MissingCategories); {
+	case missing == 0:
+		coverageResult.Status = StatusPass
+		tagsPosFeedback = append(tagsPosFeedback, "Your tags cover genre, sub-genre, theme, feature and visual style.")
+	case missing <= 2:
+		coverageResult.Status = StatusPartial
+		coverageResult.Suggestion = fmt.Sprintf("Add tags for the missing categories: %s.", strings.Join(analysis.MissingCategories, ", "))
+	default:
+		coverageResult.Status = StatusFail
+		coverageResult.Suggestion = fmt.Sprintf("Your tags miss several categories: %s. Cover each of them so players can find your game from different angles.", strings.Join(analysis.MissingCategories, ", "))
+	}
 
-	var foundTags []string
-	for _, tag := range tags {
-		lTag := strings.ToLower(tag)
-		if _, ok := genreToTags[lTag]; ok {
-			lTags := genreToTags[lTag]
-			foundTags = append(foundTags, lTags...)
+	redundancyResult := ChecklistResult{ID: "tags_no_redundancy", Status: StatusPass}
+	if len(analysis.RedundantTags) > 0 {
+		var redundant []string
+		for _, r := range analysis.RedundantTags {
+			redundant = append(redundant, fmt.Sprintf("%s (covered by %s)", r.Tag, r.ImpliedBy))
 		}
+		redundancyResult.Status = StatusPartial
+		redundancyResult.Evidence = strings.Join(redundant, ", ")
+		redundancyResult.Suggestion = "Some tags are already covered by more specific ones. Consider replacing them with tags from categories you are missing."
 	}
 
-	if len(tags) >= 10 {
-		runningTotal += 1
-		tagsPosFeedback = append(tagsPosFeedback, "You have at least 10 tags which should increase search visibility.")
-	} else {
-		tagsNegFeedback = append(tagsNegFeedback, "Consider adding more than 10 tags. You should have anywhere from 15-25 tags for optical visibility results.")
+	contradictionResult := ChecklistResult{ID: "tags_no_contradictions", Status: StatusPass}
+	if len(analysis.ContradictoryTags) > 0 {
+		var pairs []string
+		for _, pair := range analysis.ContradictoryTags {
+			pairs = append(pairs, pair[0]+" / "+pair[1])
+		}
+		contradictionResult.Status = StatusFail
+		contradictionResult.Evidence = strings.Join(pairs, ", ")
+		contradictionResult.Suggestion = "Some of your tags contradict each other and send mixed signals to players. Keep the one that describes your game best."
 	}
 
-	if len(foundTags) >= 5 {
-		runningTotal += 4
-		tagsPosFeedback = append(tagsPosFeedback, "Your tags seem to align with your genre.")
-	} else {
-		runningTotal += 2
-		tagsNegFeedback = append(tagsNegFeedback, "Consider adding more tags that align with your genre.")
+	checklist := []ChecklistResult{countResult, alignResult, coverageResult, redundancyResult, contradictionResult}
+	for _, r := range checklist {
+		if r.Suggestion != "" {
+			tagsNegFeedback = append(tagsNegFeedback, r.Suggestion)
+		}
 	}
 
 	spscr := &SteamPageSingleComponentRating{
-		Score:              string(runningTotal),
+		Component:          tagsComponent.Name,
+		Score:              int(ScoreChecklist(tagsComponent.ID, checklist)),
 		ActionableFeedback: strings.Join(tagsNegFeedback, " "),
 		Strengths:          strings.Join(tagsPosFeedback, " "),
+		Checklist:          ResolveChecklist(tagsComponent.ID, checklist),
 	}
 
-	return spscr
+	return spscr, analysis
 }
 
-func GetSteamPageEvalPrompt(ctx *SteamPagePromptCtx) string {
-	genresString := strings.Join(ctx.Genres, ", ")
-	highlightImageCaptionsString := strings.Join(ctx.HighlightImageCaptions, ",\n")
+// raterSystemInstruction is the persona rating requests run with.
+const raterSystemInstruction = "You are a Steam page rating expert. You evaluate the store pages of games against checklists and give specific, actionable feedback."
 
+func GetSteamPageEvalPrompt(ctx *SteamPagePromptCtx) string {
 	exampleEvaluation := `Description context:
-	Parse-O-Rhythm is a rhythm game about slashing errors in files to fix them. Slice and dice your way through files with nothing but the mouse and two buttons!
-	Checklist:
-	- Does it mention gameplay verbs?
-	- Does it have a hook?
-	- Does it mention at least one game genre?
-	- Is it grammatically correct?
-	Evaluation Results:
-	{
-		"description": {
-			"score": "10",
-			"actionablefeedback": "",
-			"strengths": "The description effectively uses gameplay verbs such as 'slashing' and 'slice and dice,' includes a strong hook, mentions the rhythm game genre, and is grammatically correct. It concisely communicates the core gameplay while being engaging."
+			Parse-O-Rhythm is a rhythm game about slashing errors in files to fix them. Slice and dice your way through files with nothing but the mouse and two buttons!
+			Evaluation Results:
+			{
+				"description": {
+					"checklist": [
+						{"id": "desc_gameplay_verbs", "status": "pass", "evidence": "slashing errors in files, slice and dice", "suggestion": ""},
+						{"id": "desc_hook", "status": "pass", "evidence": "a rhythm game about slashing errors in files to fix them", "suggestion": ""},
+						{"id": "desc_genre", "status": "pass", "evidence": "rhythm game", "suggestion": ""},
+						{"id": "desc_grammar", "status": "pass", "evidence": "", "suggestion": ""}
+					],
+					"score": 95,
+					"actionablefeedback": "",
+					"strengths": "The description effectively uses gameplay verbs such as 'slashing' and 'slice and dice,' includes a strong hook, mentions the rhythm game genre, and is grammatically correct. It concisely communicates the core gameplay while being engaging."
+				}
+			}`
+
+	var components strings.Builder
+	for _, c := range steamPageComponents {
+		if c.Note != "" {
+			fmt.Fprintf(&components, "\t\t\t%s context (%s):\n", c.Label, c.Note)
+		} else {
+			fmt.Fprintf(&components, "\t\t\t%s context:\n", c.Label)
 		}
-	}`
+		fmt.Fprintf(&components, "\t\t\t%s\n", ctx.componentContext(c.ID))
+		writeChecklist(&components, c.ID, "\t\t\t")
+		if c.ID == "description" {
+			fmt.Fprintf(&components, "\t\t\tHere is an example evaluation:\n\t\t\t%s\n", exampleEvaluation)
+		}
+		components.WriteString("\n")
+	}
 
 	promptTemplate := `
-		As a Steam page rating expert, you are tasked with evaluating a Steam page's content separated into components. Please follow the directions and rate the components on a scale of 1-5 based solely on the checklist criteria below.
-
-        1. Use the following scoring system:
-            - 5 points: All checklist criteria are met for the component.
-            - 4 points: Most checklist criteria are met for the component (3 out of 4, or 2 out of 3 for 3-item lists).
-            - 3 points: About half of the checklist criteria are met (approximately 50%% or 2 out of 4).
-            - 2 points: Some checklist criteria are met (approximately 25%% or 1 out of 4).
-            - 1 point: Very few checklist criteria are met.
-
-        2. Evaluate each of the components below based on each individual context:
-            Description context:
-            %s
-			Checklist:
-			- Does it mention gameplay verbs?
-			- Does it have a hook?
-			- Does it mention at least one game genre?
-			- Is it grammatically correct?
-			Here is an example evaluation:
-			%s
-
-			AboutThisGame context:
-			%s
-			Checklist:
-			- Does it mention key features and mechanics?
-			- Does it explain what you do in the game and what the gameplay is like?
-			- Does it contain a call to action regarding directing players to engage with the game?
-			- Does it briefly explain the game's core concept or unique selling point?
-
-			Genres context:
-			Genres: %s
-			Checklist:
-			- Do the listed genres align with the game's Description component?
-			- Do the listed genres align with the game's AboutThisGame component?
-			- Do the listed genres mention any of the following genres: %s
-
-			HighlightImage context (image to text descriptions, so be flexible and don't grade it harshly):
-			%s
-			Checklist:
-			- Are the images context described well?
-			- Are the descriptions concise and straight to the point?
-			- Are there elements in the context that would intrigue potential players?
-			- Does the context hint at the game's core mechanics or unique features?
-			- Do the images context collectively showcase various aspects of the game (e.g., environment, characters, gameplay)?
-
-			CapsuleImage context:
-			%s
-			Checklist:
-			- Does it have the game title in the context text?
-			- Does it show a theme or atmosphere in the background?
-
+		Evaluate the Steam page's content below, separated into components. Please follow the directions and evaluate every component against each item of its checklist.
+
+		1. For every checklist item decide if the component passes it:
+			- "status": "pass" when the component clearly meets the criteria.
+			- "status": "partial" when the criteria is only partly met.
+			- "status": "fail" when the criteria is not met.
+			- "evidence": a short quote from the context that supports the verdict, empty if there is none.
+			- "suggestion": a specific improvement for "partial" or "fail" items, empty for "pass" items.
+			- Refer to each item by the id shown in square brackets.
+			- "score": your overall score for the component, a whole number from 0 to 100.
+
+		2. Evaluate each of the components below based on each individual context:
+%s
 		3. Please provide your evaluation in the following JSON format for the output:
 
 			json
-			{
-				"description": {
-					"score": "",
-					"actionablefeedback": "",
-					"strengths": ""
-				},
-				"aboutThisGame": {
-					"score": "",
-					"actionablefeedback": "",
-					"strengths": ""
-				},
-				"genres": {
-					"score": "",
-					"actionablefeedback": "",
-					"strengths": ""
-				},
-				"highlightImageCaptions": {
-					"score": "",
-					"actionablefeedback": "",
-					"strengths": ""
-				},
-				"capsuleImageCaption": {
-					"score": "",
-					"actionablefeedback": "",
-					"strengths": ""
-				}
-			}
+%s
+
 		4. Remember to adhere to the rules below:
-			- The score should be based solely on the checklist criteria.
-			- For components with 3 or 4 checklist items, a score of 4 is awarded if 2 or 3 criteria are met.
+			- Report every checklist item of every component exactly once.
+			- Base each verdict solely on the checklist criteria.
 			- Provide actionable feedback for any unmet criteria.
 			- Sentences should be at least 60 characters long and include specific suggestions for improvement.
 		`
 
 	return fmt.Sprintf(promptTemplate,
-		ctx.Description,
-		exampleEvaluation,
-		ctx.AboutThisGame,
-		genresString,
-		genresString,
-		highlightImageCaptionsString,
-		ctx.CapsuleImageCaption,
+		components.String(),
+		checklistOutputFormat("\t\t\t"),
 	)
 }
 
+// GetRatingRepairPrompt is sent after a rejected answer, which stays in the
+// conversation as the previous model turn.
+func GetRatingRepairPrompt(validationErr error) string {
+	promptTemplate := `
+		Your previous answer was rejected because it did not match the required JSON format.
+			Problems found:
+			%s
+
+		Answer again with the complete evaluation, fixing every problem listed above and following the JSON format exactly.
+		`
+
+	return fmt.Sprintf(promptTemplate, validationErr.Error())
+}
+
 func AddImgCaptionToCtx(sppc *SteamPagePromptCtx, spiList []SteamPageImg) error {
 	if len(spiList) == 0 {
 		return fmt.Errorf("no images found")
@@ -482,9 +378,10 @@ func AddImgCaptionToCtx(sppc *SteamPagePromptCtx, spiList []SteamPageImg) error
 	return nil
 }
 
-func (s *SteamRater) ExtractImgUrlsGenerateText(spc *SteamPageContent) []SteamPageImg {
+func (s *SteamRater) ExtractImgUrlsGenerateText(ctx context.Context, spc *SteamPageContent) []SteamPageImg {
 	var imgUrlContextList []SteamPageImg
-	for _, imgUrl := range spc.HighlightImgUrls[:3] {
+	// pages can have less than three highlight images
+	for _, imgUrl := range spc.HighlightImgUrls[:min(highlightImgs, len(spc.HighlightImgUrls))] {
 		img := SteamPageImg{
 			Url:     imgUrl,
 			ImgType: "highlight",
@@ -504,21 +401,21 @@ func (s *SteamRater) ExtractImgUrlsGenerateText(spc *SteamPageContent) []SteamPa
 
 		go func(spi *SteamPageImg) {
 			defer wg.Done()
-			s.logger.InfoLog.Println("downloading img from url:", spi.Url)
-			DownloadSteamImg(spi)
+			s.logger.InfoContext(ctx, "downloading img", "url", spi.Url)
+			DownloadSteamImg(ctx, spi)
 		}(&imgUrlContextList[i])
 	}
 	wg.Wait()
-	s.logger.InfoLog.Println("successful extraction and generation of img text")
+	s.logger.InfoContext(ctx, "successful extraction and generation of img text")
 
 	//creating slice to pass underlying array reference
 	imgUrlSlice := imgUrlContextList[:]
-	s.ProcessImgCaptions(imgUrlSlice, spc)
+	s.ProcessImgCaptions(ctx, imgUrlSlice, spc)
 
 	return imgUrlContextList
 }
 
-func (s *SteamRater) ProcessImgCaptions(imgUrlContextList []SteamPageImg, spc *SteamPageContent) {
+func (s *SteamRater) ProcessImgCaptions(ctx context.Context, imgUrlContextList []SteamPageImg, spc *SteamPageContent) {
 	var wg sync.WaitGroup
 	for i := range imgUrlContextList {
 		wg.Add(1)
@@ -533,18 +430,18 @@ func (s *SteamRater) ProcessImgCaptions(imgUrlContextList []SteamPageImg, spc *S
 				imgContext = "This is a video game steam page capsule image, What's the title? What's the theme of the background like? Describe it in two short and concise sentences."
 			}
 
-			err := s.ProcessImgToText(spi, imgContext)
+			err := s.ProcessImgToText(ctx, spi, imgContext)
 			if err != nil {
-				s.logger.ErrorLog.Println(err.Error())
+				s.logger.ErrorContext(ctx, "captioning img", "url", spi.Url, "err", err)
 			}
 		}(&imgUrlContextList[i])
 
 	}
 	wg.Wait()
-	s.logger.InfoLog.Println("finished processing img captions")
+	s.logger.InfoContext(ctx, "finished processing img captions")
 }
 
-func (s *SteamRater) ProcessImgToText(spi *SteamPageImg, imgContext string) error {
+func (s *SteamRater) ProcessImgToText(ctx context.Context, spi *SteamPageImg, imgContext string) error {
 	type ImgToTextResponse struct {
 		Result   Description `json:"result"`
 		Success  bool        `json:"success"`
@@ -565,37 +462,47 @@ func (s *SteamRater) ProcessImgToText(spi *SteamPageImg, imgContext string) erro
 
 	jsonInput, err := json.Marshal(inputData)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
 		return err
 	}
 
-	bodyBytes, err := s.cfSvc.CallImgToTextApi(jsonInput)
+	bodyBytes, err := s.cfSvc.CallImgToTextApi(ctx, jsonInput)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
 		return err
 	}
 
 	imgResponse := ImgToTextResponse{}
 	err = json.Unmarshal(bodyBytes, &imgResponse)
 	if err != nil {
-		log.Println("Error unmarshaling JSON:", err)
-		return err
+		return fmt.Errorf("decoding img to text response: %w", err)
 	}
 
-	log.Printf("Img description %s\n", imgResponse.Result.Description)
+	s.logger.DebugContext(ctx, "img description", "url", spi.Url, logger.Sensitive("description", imgResponse.Result.Description))
 	spi.ImgCaption = imgResponse.Result.Description
 	return nil
 }
 
-func DownloadSteamImg(spi *SteamPageImg) {
-	resp, err := http.Get(spi.Url)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		log.Println("Could not download img")
+func DownloadSteamImg(ctx context.Context, spi *SteamPageImg) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spi.Url, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not create img request", "url", spi.Url, "err", err)
+		return
+	}
+
+	resp, err := imgClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not download img", "url", spi.Url, "err", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.ErrorContext(ctx, "could not download img", "url", spi.Url, "status", resp.StatusCode)
+		return
 	}
 
 	imgBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("Could not read downloaded img bytes")
+		slog.ErrorContext(ctx, "could not read downloaded img bytes", "url", spi.Url, "err", err)
+		return
 	}
 	spi.ImgBytes = imgBytes
 }
@@ -609,24 +516,64 @@ type SteamPagePromptCtx struct {
 }
 
 type SteamPageRatingResult struct {
+	Id                 string                           `json:"id,omitempty"`
 	FinalWeightedScore int                              `json:"finalWeightedScore"`
 	CapsuleUrl         string                           `json:"capsuleUrl"`
 	ComponentRatings   []SteamPageSingleComponentRating `json:"componentRatings"`
+	TagAnalysis        *TagAnalysis                     `json:"tagAnalysis,omitempty"`
 }
 
 type LLMInnerResponse struct {
-	Description            SteamPageSingleComponentRating `json:"description"`
-	AboutThisGame          SteamPageSingleComponentRating `json:"aboutThisGame"`
-	Genres                 SteamPageSingleComponentRating `json:"genres"`
-	HighlightImageCaptions SteamPageSingleComponentRating `json:"highlightImageCaptions"`
-	CapsuleImageCaption    SteamPageSingleComponentRating `json:"capsuleImageCaption"`
+	Description            LLMComponentEvaluation `json:"description"`
+	AboutThisGame          LLMComponentEvaluation `json:"aboutThisGame"`
+	Genres                 LLMComponentEvaluation `json:"genres"`
+	HighlightImageCaptions LLMComponentEvaluation `json:"highlightImageCaptions"`
+	CapsuleImageCaption    LLMComponentEvaluation `json:"capsuleImageCaption"`
+}
+
+type LLMComponentEvaluation struct {
+	Checklist          []ChecklistResult `json:"checklist"`
+	Score              int               `json:"score" schema:"min=0,max=100" description:"overall quality of the component from 0 to 100"`
+	ActionableFeedback string            `json:"actionablefeedback"`
+	Strengths          string            `json:"strengths"`
+}
+
+func (r *LLMInnerResponse) evaluation(component string) *LLMComponentEvaluation {
+	switch component {
+	case "description":
+		return &r.Description
+	case "aboutThisGame":
+		return &r.AboutThisGame
+	case "genres":
+		return &r.Genres
+	case "highlightImageCaptions":
+		return &r.HighlightImageCaptions
+	case "capsuleImageCaption":
+		return &r.CapsuleImageCaption
+	}
+	return &LLMComponentEvaluation{}
+}
+
+func (e LLMComponentEvaluation) toRating(componentId string, score float64) SteamPageSingleComponentRating {
+	modelScore := e.Score
+	return SteamPageSingleComponentRating{
+		Component:          componentName(componentId),
+		Score:              int(score),
+		ModelScore:         &modelScore,
+		ActionableFeedback: e.ActionableFeedback,
+		Strengths:          e.Strengths,
+		Checklist:          ResolveChecklist(componentId, e.Checklist),
+	}
 }
 
 type SteamPageSingleComponentRating struct {
-	Component          string `json:"component,omitempty"`
-	Score              string `json:"score"`
-	ActionableFeedback string `json:"actionablefeedback"`
-	Strengths          string `json:"strengths,omitempty"`
+	Component string `json:"component,omitempty"`
+	Score     int    `json:"score"`
+	// ModelScore is the model's own 0-100 score, the checklist decides Score
+	ModelScore         *int              `json:"modelScore,omitempty"`
+	ActionableFeedback string            `json:"actionablefeedback"`
+	Strengths          string            `json:"strengths,omitempty"`
+	Checklist          []ChecklistResult `json:"checklist,omitempty"`
 }
 
 type SteamPageImg struct {
@@ -0,0 +1,239 @@
+package steamrating
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/pdf"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	ReportMarkdown = "md"
+	ReportHTML     = "html"
+	ReportPDF      = "pdf"
+
+	maxCapsuleBytes = 5 << 20
+)
+
+var ErrUnknownReportFormat = errors.New("unknown report format, use md, html or pdf")
+
+//go:embed templates/report.md.tmpl templates/report.html.tmpl
+var reportTemplates embed.FS
+
+var reportFuncs = map[string]interface{}{
+	"status": func(status string) string { return strings.ToUpper(status) },
+	"join":   func(s []string) string { return strings.Join(s, ", ") },
+}
+
+var (
+	markdownReport = template.Must(template.New("report.md.tmpl").Funcs(reportFuncs).ParseFS(reportTemplates, "templates/report.md.tmpl"))
+	htmlReport     = htmltemplate.Must(htmltemplate.New("report.html.tmpl").Funcs(reportFuncs).ParseFS(reportTemplates, "templates/report.html.tmpl"))
+)
+
+// ReportRenderer turns a stored rating into a shareable report.
+type ReportRenderer struct {
+	logger     *logger.AppLogger
+	httpClient *http.Client
+}
+
+func NewReportRenderer(logger *logger.AppLogger) *ReportRenderer {
+	return &ReportRenderer{
+		logger: logger,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+type reportData struct {
+	Title      string
+	AppId      string
+	Url        string
+	CreatedAt  string
+	Score      int
+	CapsuleUrl string
+	// CapsuleSrc is the capsule image as a data uri for self-contained html
+	CapsuleSrc htmltemplate.URL
+	Components []SteamPageSingleComponentRating
+	Tags       *TagAnalysis
+	// SweetSpot is the recommended tag count, as in "15 to 20"
+	SweetSpot string
+}
+
+// Render returns the report in the given format along with its content type.
+func (r *ReportRenderer) Render(ctx context.Context, record *RatingRecord, format string) ([]byte, string, error) {
+	data := newReportData(record)
+
+	var buf bytes.Buffer
+	switch format {
+	case ReportMarkdown:
+		if err := markdownReport.Execute(&buf, data); err != nil {
+			return nil, "", fmt.Errorf("rendering markdown report: %w", err)
+		}
+		return buf.Bytes(), "text/markdown; charset=utf-8", nil
+
+	case ReportHTML:
+		data.CapsuleSrc = r.capsuleDataUri(ctx, data.CapsuleUrl)
+		if err := htmlReport.Execute(&buf, data); err != nil {
+			return nil, "", fmt.Errorf("rendering html report: %w", err)
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+
+	case ReportPDF:
+		return renderPdfReport(data), "application/pdf", nil
+	}
+
+	return nil, "", ErrUnknownReportFormat
+}
+
+func newReportData(record *RatingRecord) reportData {
+	data := reportData{
+		Title:     record.Title,
+		AppId:     record.AppId,
+		Url:       record.Url,
+		CreatedAt: record.CreatedAt.Format("January 2, 2006"),
+		SweetSpot: fmt.Sprintf("%d to %d", sweetSpotTags, maxTagCount),
+	}
+	if data.Title == "" {
+		data.Title = "Steam app " + record.AppId
+	}
+
+	if record.Rating != nil {
+		data.Score = record.Rating.FinalWeightedScore
+		data.CapsuleUrl = record.Rating.CapsuleUrl
+		data.Components = record.Rating.ComponentRatings
+		data.Tags = record.Rating.TagAnalysis
+	}
+	return data
+}
+
+// capsuleDataUri downloads the capsule image so the html report does not
+// depend on steam's cdn. It returns an empty uri when the download fails and
+// the template falls back to the image url.
+func (r *ReportRenderer) capsuleDataUri(ctx context.Context, url string) htmltemplate.URL {
+	if url == "" {
+		return ""
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "creating capsule image request", "err", err)
+		return ""
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "downloading capsule image", "err", err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(contentType, "image/") {
+		r.logger.ErrorContext(ctx, "capsule image is not an image", "status", resp.StatusCode, "contentType", contentType)
+		return ""
+	}
+
+	img, err := io.ReadAll(io.LimitReader(resp.Body, maxCapsuleBytes+1))
+	if err != nil || len(img) > maxCapsuleBytes {
+		r.logger.ErrorContext(ctx, "capsule image could not be read or is too large", "err", err)
+		return ""
+	}
+
+	return htmltemplate.URL("data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(img))
+}
+
+func renderPdfReport(data reportData) []byte {
+	doc := pdf.New()
+
+	doc.SetFont(true, 20)
+	doc.Paragraph("Steam page rating: " + data.Title)
+	doc.SetColor(0.4, 0.44, 0.48)
+	doc.SetFont(false, 10)
+	if data.Url != "" {
+		doc.Paragraph(data.Url)
+	}
+	doc.Paragraph("Rated on " + data.CreatedAt)
+	doc.SetColor(0, 0, 0)
+
+	doc.Space(8)
+	doc.SetFont(true, 28)
+	doc.Paragraph(fmt.Sprintf("%d/100", data.Score))
+	doc.SetFont(false, 11)
+	for _, c := range data.Components {
+		doc.Paragraph(fmt.Sprintf("%s: %d/100", c.Component, c.Score))
+	}
+
+	for _, c := range data.Components {
+		doc.Rule()
+		doc.SetFont(true, 14)
+		doc.Paragraph(fmt.Sprintf("%s: %d/100", c.Component, c.Score))
+		doc.SetFont(false, 11)
+		if c.Strengths != "" {
+			doc.Paragraph("Strengths: " + c.Strengths)
+		}
+		doc.Paragraph("Feedback: " + c.ActionableFeedback)
+
+		if len(c.Checklist) > 0 {
+			doc.Space(4)
+		}
+		for _, item := range c.Checklist {
+			switch item.Status {
+			case StatusPass:
+				doc.SetColor(0.3, 0.54, 0.18)
+			case StatusPartial:
+				doc.SetColor(0.76, 0.56, 0)
+			default:
+				doc.SetColor(0.7, 0.21, 0.17)
+			}
+			doc.SetFont(true, 10)
+			doc.Paragraph(strings.ToUpper(item.Status))
+			doc.SetColor(0, 0, 0)
+			doc.SetFont(false, 10)
+			doc.ParagraphIndent(item.Question, 12)
+			if item.Suggestion != "" {
+				doc.SetColor(0.4, 0.44, 0.48)
+				doc.ParagraphIndent(item.Suggestion, 12)
+				doc.SetColor(0, 0, 0)
+			}
+			doc.Space(3)
+		}
+	}
+
+	if t := data.Tags; t != nil {
+		doc.Rule()
+		doc.SetFont(true, 14)
+		doc.Paragraph("Tag analysis")
+		doc.SetFont(false, 11)
+
+		count := fmt.Sprintf("%d tags", t.TagCount)
+		if t.InSweetSpot {
+			count += fmt.Sprintf(", in the %s sweet spot", data.SweetSpot)
+		}
+		doc.Paragraph(count)
+		if len(t.MissingCategories) > 0 {
+			doc.Paragraph("Missing tag categories: " + strings.Join(t.MissingCategories, ", "))
+		}
+		for _, rt := range t.RedundantTags {
+			doc.Paragraph(fmt.Sprintf("%q is already implied by %q", rt.Tag, rt.ImpliedBy))
+		}
+		for _, ct := range t.ContradictoryTags {
+			doc.Paragraph(fmt.Sprintf("%q contradicts %q", ct[0], ct[1]))
+		}
+		if len(t.UnknownTags) > 0 {
+			doc.Paragraph("Not steam tags: " + strings.Join(t.UnknownTags, ", "))
+		}
+	}
+
+	return doc.Bytes()
+}
@@ -0,0 +1,84 @@
+package steamrating
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gdrsapi/pkg/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRatingRecord() *RatingRecord {
+	return &RatingRecord{
+		ID:        "abc123",
+		AppId:     "620",
+		Url:       "https://store.steampowered.com/app/620/Portal_2/",
+		Title:     "Portal 2",
+		CreatedAt: time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC),
+		Rating: &SteamPageRatingResult{
+			FinalWeightedScore: 72,
+			ComponentRatings: []SteamPageSingleComponentRating{
+				{
+					Component:          "Description",
+					Score:              75,
+					ActionableFeedback: "Lead with the <portal> mechanic.",
+					Strengths:          "Clear genre.",
+					Checklist: []ChecklistResult{
+						{ID: "desc_hook", Question: "Does it have a hook?", Status: StatusPartial, Suggestion: "Open with a question."},
+						{ID: "desc_genre", Question: "Does it mention at least one game genre?", Status: StatusPass},
+					},
+				},
+			},
+			TagAnalysis: &TagAnalysis{TagCount: 9, MissingCategories: []string{"visual style"}},
+		},
+	}
+}
+
+func TestRenderReport(t *testing.T) {
+	r := NewReportRenderer(logger.NewAppLogger())
+	record := testRatingRecord()
+
+	md, contentType, err := r.Render(context.Background(), record, ReportMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# Steam page rating: Portal 2", "**Overall score: 72/100**", "| Description | 75/100 |", "- **PARTIAL** Does it have a hook?", "Missing tag categories: visual style"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("markdown report is missing %q:\n%s", want, md)
+		}
+	}
+	if !strings.HasPrefix(contentType, "text/markdown") {
+		t.Errorf("unexpected markdown content type %s", contentType)
+	}
+
+	html, _, err := r.Render(context.Background(), record, ReportHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "Lead with the &lt;portal&gt; mechanic.") {
+		t.Error("expected the html report to escape llm feedback")
+	}
+
+	doc, _, err := r.Render(context.Background(), record, ReportPDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(doc, []byte("%PDF")) {
+		t.Error("expected a pdf document")
+	}
+
+	record.Rating.TagAnalysis = &TagAnalysis{TagCount: sweetSpotTags, InSweetSpot: true}
+	md, _, err = r.Render(context.Background(), record, ReportMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%d tags, in the %d to %d sweet spot", sweetSpotTags, sweetSpotTags, maxTagCount); !strings.Contains(string(md), want) {
+		t.Errorf("markdown report is missing %q:\n%s", want, md)
+	}
+
+	if _, _, err := r.Render(context.Background(), record, "docx"); err != ErrUnknownReportFormat {
+		t.Errorf("expected ErrUnknownReportFormat, got %v", err)
+	}
+}
@@ -1,11 +1,15 @@
 package steamrating
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"gdrsapi/pkg/logger"
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,14 +17,18 @@ import (
 )
 
 const (
-	ageNeededUrl = "https://store.steampowered.com/agecheck/app/1840080/"
-	ageSetUrl    = "https://store.steampowered.com/agecheckset/app/1840080/"
-	day          = "23"
-	month        = "2"
-	year         = "1992"
+	steamStoreHost = "store.steampowered.com"
+	steamAppUrl    = "https://store.steampowered.com/app/"
+	steamSearchUrl = "https://store.steampowered.com/search/"
+	ageNeededUrl   = "https://store.steampowered.com/agecheck/app/1840080/"
+	ageSetUrl      = "https://store.steampowered.com/agecheckset/app/1840080/"
+	day            = "23"
+	month          = "2"
+	year           = "1992"
 )
 
 type SteamPageContent struct {
+	Title            string
 	CapsuleImgUrl    string
 	CapsuleDesc      string
 	Genres           []string
@@ -47,19 +55,18 @@ func NewSteamScraper(logger *logger.AppLogger) *SteamScraper {
 	}
 }
 
-func (s *SteamScraper) VerifySteamAgeCheck(steamUrl string) (io.ReadCloser, error) {
-	s.logger.InfoLog.Println("starting age check")
+func (s *SteamScraper) VerifySteamAgeCheck(ctx context.Context, steamUrl string) (io.ReadCloser, error) {
+	s.logger.InfoContext(ctx, "starting age check", "url", steamUrl)
 	var sessionID string
 
-	req, err := http.NewRequest("GET", ageNeededUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", ageNeededUrl, nil)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
+		s.logger.ErrorContext(ctx, "fetching age verification page", "err", err)
 		return nil, fmt.Errorf("fetching age verification page: %w", err)
 	}
 	defer resp.Body.Close()
@@ -81,7 +88,7 @@ func (s *SteamScraper) VerifySteamAgeCheck(steamUrl string) (io.ReadCloser, erro
 		}
 	}
 	if sessionID == "" {
-		s.logger.ErrorLog.Println("session ID cookie not found")
+		s.logger.ErrorContext(ctx, "session ID cookie not found")
 		return nil, fmt.Errorf("session ID cookie not found")
 	}
 
@@ -93,25 +100,27 @@ func (s *SteamScraper) VerifySteamAgeCheck(steamUrl string) (io.ReadCloser, erro
 	}
 
 	// Submit age verification
-	verifyReq, err := http.NewRequest("POST", ageSetUrl, strings.NewReader(formData.Encode()))
+	verifyReq, err := http.NewRequestWithContext(ctx, "POST", ageSetUrl, strings.NewReader(formData.Encode()))
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
 		return nil, fmt.Errorf("creating verification request: %w", err)
 	}
 	verifyReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	verifyReq.AddCookie(&http.Cookie{Name: "sessionid", Value: sessionID})
 
 	verifyResp, err := s.httpClient.Do(verifyReq)
-	if err != nil || verifyResp.StatusCode != http.StatusOK {
-		s.logger.ErrorLog.Println(err.Error())
+	if err != nil {
+		s.logger.ErrorContext(ctx, "submitting age verification", "err", err)
 		return nil, fmt.Errorf("age verification error: %w", err)
 	}
 	defer verifyResp.Body.Close()
+	if verifyResp.StatusCode != http.StatusOK {
+		s.logger.ErrorContext(ctx, "submitting age verification", "status", verifyResp.StatusCode)
+		return nil, fmt.Errorf("age verification error: status=%d", verifyResp.StatusCode)
+	}
 
 	// Fetch game page
-	gameReq, err := http.NewRequest("GET", steamUrl, nil)
+	gameReq, err := http.NewRequestWithContext(ctx, "GET", steamUrl, nil)
 	if err != nil {
-		s.logger.ErrorLog.Println(err.Error())
 		return nil, fmt.Errorf("creating game page request: %w", err)
 	}
 
@@ -121,21 +130,30 @@ func (s *SteamScraper) VerifySteamAgeCheck(steamUrl string) (io.ReadCloser, erro
 	gameReq.AddCookie(&http.Cookie{Name: "sessionid", Value: sessionID})
 
 	gameResp, err := s.httpClient.Do(gameReq)
-	if err != nil || gameResp.StatusCode != http.StatusOK {
-		s.logger.ErrorLog.Println(err.Error())
+	if err != nil {
+		s.logger.ErrorContext(ctx, "fetching game page after the age check", "url", steamUrl, "err", err)
 		return nil, fmt.Errorf("fetching game page error: %w", err)
 	}
+	if gameResp.StatusCode != http.StatusOK {
+		gameResp.Body.Close()
+		s.logger.ErrorContext(ctx, "fetching game page after the age check", "url", steamUrl, "status", gameResp.StatusCode)
+		return nil, fmt.Errorf("fetching game page error: status=%d", gameResp.StatusCode)
+	}
 
 	return gameResp.Body, nil
 }
 
-func (s *SteamScraper) ScrapeSteamPage(steamUrl string) (*SteamPageContent, error) {
+func (s *SteamScraper) ScrapeSteamPage(ctx context.Context, steamUrl string) (*SteamPageContent, error) {
 	pageContent := &SteamPageContent{}
 
-	res, err := s.httpClient.Get(steamUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", steamUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	res, err := s.httpClient.Do(req)
 	if err != nil {
-		s.logger.InfoLog.Println("This is inside ScrapeSteamPage")
-		s.logger.ErrorLog.Println(err.Error())
+		s.logger.ErrorContext(ctx, "fetching steam page", "url", steamUrl, "err", err)
 		return nil, fmt.Errorf("HTTP error")
 	}
 	defer res.Body.Close()
@@ -149,32 +167,34 @@ func (s *SteamScraper) ScrapeSteamPage(steamUrl string) (*SteamPageContent, erro
 		return nil, fmt.Errorf("API error: status=%d, body=%s", res.StatusCode, string(bodyBytes))
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	// the body was already read to report errors, so parse the bytes
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyBytes))
 	if err != nil {
-		s.logger.ErrorLog.Printf("Failed to parse the HTML document%s", err)
+		s.logger.ErrorContext(ctx, "parsing steam page", "url", steamUrl, "err", err)
 		return nil, fmt.Errorf("failed to parse the HTML document")
 	}
 
 	capsuleSection := doc.Find(".glance_ctn")
 	if capsuleSection.Length() == 0 {
-		s.logger.InfoLog.Println("Retrying to find the capsule section")
+		s.logger.InfoContext(ctx, "capsule section not found, retrying past the age check", "url", steamUrl)
 
-		resBody, err := s.VerifySteamAgeCheck(steamUrl)
+		resBody, err := s.VerifySteamAgeCheck(ctx, steamUrl)
 		if err != nil {
 			return nil, err
 		}
+		defer resBody.Close()
 
 		doc, _ = goquery.NewDocumentFromReader(resBody)
 		capsuleSection = doc.Find(".glance_ctn")
 		if capsuleSection.Length() == 0 {
 			return nil, fmt.Errorf("failed to find the capsule section")
 		}
-		s.logger.InfoLog.Println("found capsule section")
+		s.logger.InfoContext(ctx, "found capsule section", "url", steamUrl)
 	}
 
 	descriptionNode := capsuleSection.Find(".game_description_snippet")
 	if descriptionNode.Length() == 0 {
-		s.logger.ErrorLog.Println("Failed to grab capsule description")
+		s.logger.ErrorContext(ctx, "capsule description not found", "url", steamUrl)
 		return nil, fmt.Errorf("failed to grab capsule description")
 	}
 	description := strings.TrimSpace(descriptionNode.Text())
@@ -185,20 +205,20 @@ func (s *SteamScraper) ScrapeSteamPage(steamUrl string) (*SteamPageContent, erro
 	})
 
 	if len(tags) == 0 {
-		s.logger.ErrorLog.Println("no tags found")
+		s.logger.ErrorContext(ctx, "no tags found", "url", steamUrl)
 		return nil, fmt.Errorf("no tags found")
 	}
 
 	//extract genres
 	genreSection := doc.Find("#appDetailsUnderlinedLinks")
 	if genreSection.Length() == 0 {
-		s.logger.ErrorLog.Println("genre section not found")
+		s.logger.ErrorContext(ctx, "genre section not found", "url", steamUrl)
 		return nil, fmt.Errorf("genre section not found")
 	}
 
 	genreNodes := genreSection.Find("#genresAndManufacturer > span:first-of-type a")
 	if genreNodes.Length() == 0 {
-		s.logger.ErrorLog.Println("genre nodes not found")
+		s.logger.ErrorContext(ctx, "genre nodes not found", "url", steamUrl)
 		return nil, fmt.Errorf("genre nodes not found")
 	}
 
@@ -224,7 +244,7 @@ func (s *SteamScraper) ScrapeSteamPage(steamUrl string) (*SteamPageContent, erro
 	//extract imgUrls
 	highlightSection := doc.Find("#highlight_player_area")
 	if highlightSection.Length() == 0 {
-		s.logger.ErrorLog.Println("highlight section not found")
+		s.logger.ErrorContext(ctx, "highlight section not found", "url", steamUrl)
 		return nil, fmt.Errorf("highlight section not found")
 	}
 
@@ -237,14 +257,14 @@ func (s *SteamScraper) ScrapeSteamPage(steamUrl string) (*SteamPageContent, erro
 	})
 
 	if len(imageUrls) == 0 {
-		s.logger.ErrorLog.Println("no image URLs found")
+		s.logger.ErrorContext(ctx, "no image URLs found", "url", steamUrl)
 		return nil, fmt.Errorf("no image URLs found")
 	}
 
 	//extract about game content
 	aboutGameSection := doc.Find("#game_area_description")
 	if aboutGameSection.Length() == 0 {
-		s.logger.ErrorLog.Println("about game section not found")
+		s.logger.ErrorContext(ctx, "about game section not found", "url", steamUrl)
 		return nil, fmt.Errorf("about game section not found")
 	}
 	aboutText := strings.TrimSpace(strings.Replace(aboutGameSection.Text(), "About This Game", "", 1))
@@ -264,6 +284,7 @@ func (s *SteamScraper) ScrapeSteamPage(steamUrl string) (*SteamPageContent, erro
 		}
 	})
 
+	pageContent.Title = strings.TrimSpace(doc.Find("#appHubAppName").First().Text())
 	pageContent.CapsuleDesc = description
 	pageContent.Tags = tags[:len(tags)-1]
 	pageContent.Genres = genres
@@ -275,3 +296,90 @@ func (s *SteamScraper) ScrapeSteamPage(steamUrl string) (*SteamPageContent, erro
 
 	return pageContent, nil
 }
+
+// SteamAppUrl returns the store page url of an app.
+func SteamAppUrl(appId string) string {
+	return steamAppUrl + appId + "/"
+}
+
+// ParseSteamAppId accepts either a numeric app id or a store page url like
+// https://store.steampowered.com/app/1840080/Title/ and returns the app id.
+func ParseSteamAppId(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if validAppId(input) {
+		return input, nil
+	}
+
+	u, err := url.Parse(input)
+	if err != nil || u.Host != steamStoreHost {
+		return "", fmt.Errorf("%q is not a steam app id or store page url", input)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "app" {
+		return "", fmt.Errorf("%q is not a steam app page url", input)
+	}
+
+	if !validAppId(parts[1]) {
+		return "", fmt.Errorf("%q has an invalid app id", input)
+	}
+	return parts[1], nil
+}
+
+var appIdPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// validAppId reports whether id is made of digits only and above zero.
+func validAppId(id string) bool {
+	if !appIdPattern.MatchString(id) {
+		return false
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	return err == nil && n > 0
+}
+
+// SearchAppsByTags returns the ids of the top games on steam's search page
+// matching all of the given tag ids.
+func (s *SteamScraper) SearchAppsByTags(ctx context.Context, tagIds []int, limit int) ([]string, error) {
+	tags := make([]string, 0, len(tagIds))
+	for _, id := range tagIds {
+		tags = append(tags, strconv.Itoa(id))
+	}
+
+	query := url.Values{
+		"tags":      {strings.Join(tags, ",")},
+		"category1": {"998"}, // games only
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", steamSearchUrl+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating search request: %w", err)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "searching steam", "err", err)
+		return nil, fmt.Errorf("searching steam: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("steam search error: status=%d", res.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the search results: %w", err)
+	}
+
+	var appIds []string
+	doc.Find("#search_resultsRows a[data-ds-appid]").EachWithBreak(func(i int, sel *goquery.Selection) bool {
+		appId, _ := sel.Attr("data-ds-appid")
+		// bundles list several comma separated app ids
+		if validAppId(appId) {
+			appIds = append(appIds, appId)
+		}
+		return len(appIds) < limit
+	})
+
+	return appIds, nil
+}
@@ -0,0 +1,218 @@
+package steamrating
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:embed data/steam_tags.json
+var steamTagsData []byte
+
+// Recommended amount of user tags on a steam page.
+const (
+	minTagCount   = 10
+	sweetSpotTags = 15
+	maxTagCount   = 20
+)
+
+// SteamTag is a single tag of steam's tag taxonomy. Implies lists broader
+// tags that a page using this tag already covers. TopLevel marks the tags
+// steam also uses as store genres.
+type SteamTag struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	TopLevel bool     `json:"topLevel,omitempty"`
+	Implies  []string `json:"implies,omitempty"`
+}
+
+// GenreTags lists the tags that suit a store genre beyond the ones implying
+// its top level tag, such as "parkour" for Action.
+type GenreTags struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// TagTaxonomy is the bundled copy of steam's tags, grouped into the
+// categories a page is expected to cover.
+type TagTaxonomy struct {
+	Categories    []string    `json:"categories"`
+	Tags          []SteamTag  `json:"tags"`
+	Genres        []GenreTags `json:"genres"`
+	Contradictory [][2]string `json:"contradictory"`
+
+	byName  map[string]*SteamTag
+	byGenre map[string][]string
+}
+
+var steamTagTaxonomy = mustLoadTaxonomy(steamTagsData)
+
+// Taxonomy returns the embedded steam tag taxonomy.
+func Taxonomy() *TagTaxonomy {
+	return steamTagTaxonomy
+}
+
+func LoadTaxonomy(data []byte) (*TagTaxonomy, error) {
+	t := &TagTaxonomy{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("decode tag taxonomy: %w", err)
+	}
+
+	t.byName = make(map[string]*SteamTag, len(t.Tags))
+	for i := range t.Tags {
+		t.byName[normalizeTag(t.Tags[i].Name)] = &t.Tags[i]
+	}
+
+	t.byGenre = make(map[string][]string, len(t.Genres))
+	for _, g := range t.Genres {
+		t.byGenre[normalizeTag(g.Name)] = g.Tags
+	}
+
+	for _, tag := range t.Tags {
+		for _, implied := range tag.Implies {
+			if _, ok := t.byName[normalizeTag(implied)]; !ok {
+				return nil, fmt.Errorf("tag %q implies unknown tag %q", tag.Name, implied)
+			}
+		}
+	}
+	return t, nil
+}
+
+func mustLoadTaxonomy(data []byte) *TagTaxonomy {
+	t, err := LoadTaxonomy(data)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Lookup finds a tag by name, ignoring case and hyphenation.
+func (t *TagTaxonomy) Lookup(name string) (SteamTag, bool) {
+	tag, ok := t.byName[normalizeTag(name)]
+	if !ok {
+		return SteamTag{}, false
+	}
+	return *tag, true
+}
+
+func normalizeTag(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.ReplaceAll(name, "-", " ")
+}
+
+// genreTags returns the normalized tags that align with the page's genres:
+// the top level tag of each genre, every tag implying it and the tags listed
+// for the genre.
+func (t *TagTaxonomy) genreTags(genres []string) map[string]bool {
+	targets := make(map[string]bool)
+	for _, genre := range genres {
+		if tag, ok := t.Lookup(genre); ok && tag.TopLevel {
+			targets[normalizeTag(tag.Name)] = true
+		}
+	}
+
+	// implied tags can imply further tags, so repeat until nothing is added
+	for added := len(targets) > 0; added; {
+		added = false
+		for _, tag := range t.Tags {
+			name := normalizeTag(tag.Name)
+			if targets[name] {
+				continue
+			}
+			for _, implied := range tag.Implies {
+				if targets[normalizeTag(implied)] {
+					targets[name] = true
+					added = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, genre := range genres {
+		for _, tag := range t.byGenre[normalizeTag(genre)] {
+			targets[normalizeTag(tag)] = true
+		}
+	}
+	return targets
+}
+
+// RedundantTag is a tag already covered by a more specific tag on the page.
+type RedundantTag struct {
+	Tag       string `json:"tag"`
+	ImpliedBy string `json:"impliedBy"`
+}
+
+type TagAnalysis struct {
+	TagCount          int                 `json:"tagCount"`
+	InSweetSpot       bool                `json:"inSweetSpot"`
+	Categories        map[string][]string `json:"categories"`
+	MissingCategories []string            `json:"missingCategories"`
+	GenreAlignedTags  []string            `json:"genreAlignedTags"`
+	RedundantTags     []RedundantTag      `json:"redundantTags"`
+	ContradictoryTags [][2]string         `json:"contradictoryTags"`
+	UnknownTags       []string            `json:"unknownTags"`
+}
+
+// AnalyzeTags checks a page's tags against the taxonomy and the tags expected
+// for its genres.
+func (t *TagTaxonomy) AnalyzeTags(genres []string, tags []string) *TagAnalysis {
+	analysis := &TagAnalysis{
+		TagCount:          len(tags),
+		InSweetSpot:       len(tags) >= sweetSpotTags && len(tags) <= maxTagCount,
+		Categories:        make(map[string][]string),
+		MissingCategories: []string{},
+		GenreAlignedTags:  []string{},
+		RedundantTags:     []RedundantTag{},
+		ContradictoryTags: [][2]string{},
+		UnknownTags:       []string{},
+	}
+
+	targetTags := t.genreTags(genres)
+
+	present := make(map[string]string, len(tags))
+	for _, name := range tags {
+		present[normalizeTag(name)] = name
+
+		if targetTags[normalizeTag(name)] {
+			analysis.GenreAlignedTags = append(analysis.GenreAlignedTags, name)
+		}
+
+		tag, ok := t.Lookup(name)
+		if !ok {
+			analysis.UnknownTags = append(analysis.UnknownTags, name)
+			continue
+		}
+		analysis.Categories[tag.Category] = append(analysis.Categories[tag.Category], name)
+	}
+
+	for _, category := range t.Categories {
+		if len(analysis.Categories[category]) == 0 {
+			analysis.MissingCategories = append(analysis.MissingCategories, category)
+		}
+	}
+
+	for _, name := range tags {
+		tag, ok := t.Lookup(name)
+		if !ok {
+			continue
+		}
+		for _, implied := range tag.Implies {
+			if pageName, ok := present[normalizeTag(implied)]; ok {
+				analysis.RedundantTags = append(analysis.RedundantTags, RedundantTag{Tag: pageName, ImpliedBy: name})
+			}
+		}
+	}
+
+	for _, pair := range t.Contradictory {
+		first, okFirst := present[normalizeTag(pair[0])]
+		second, okSecond := present[normalizeTag(pair[1])]
+		if okFirst && okSecond {
+			analysis.ContradictoryTags = append(analysis.ContradictoryTags, [2]string{first, second})
+		}
+	}
+
+	return analysis
+}
@@ -0,0 +1,83 @@
+package steamrating
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeTags(t *testing.T) {
+	genres := []string{"Action", "Indie"}
+	tags := []string{"Action", "Roguelike", "Action Roguelike", "Pixel Graphics", "Relaxing", "Difficult", "Hack and Slash", "Singleplayer", "Not A Real Tag"}
+
+	analysis := Taxonomy().AnalyzeTags(genres, tags)
+
+	if analysis.TagCount != len(tags) || analysis.InSweetSpot {
+		t.Errorf("expected %d tags outside the sweet spot, got %d", len(tags), analysis.TagCount)
+	}
+
+	if !slices.Contains(analysis.GenreAlignedTags, "Hack and Slash") {
+		t.Errorf("expected Hack and Slash to align with the action genre, got %v", analysis.GenreAlignedTags)
+	}
+
+	if !slices.Equal(analysis.UnknownTags, []string{"Not A Real Tag"}) {
+		t.Errorf("unexpected unknown tags %v", analysis.UnknownTags)
+	}
+
+	if len(analysis.MissingCategories) != 0 {
+		t.Errorf("expected every category to be covered, missing %v", analysis.MissingCategories)
+	}
+
+	expectedRedundant := []RedundantTag{
+		{Tag: "Action", ImpliedBy: "Action Roguelike"},
+		{Tag: "Roguelike", ImpliedBy: "Action Roguelike"},
+	}
+	if !slices.Equal(analysis.RedundantTags, expectedRedundant) {
+		t.Errorf("unexpected redundant tags %v", analysis.RedundantTags)
+	}
+
+	if len(analysis.ContradictoryTags) != 1 || analysis.ContradictoryTags[0] != [2]string{"Relaxing", "Difficult"} {
+		t.Errorf("unexpected contradictory tags %v", analysis.ContradictoryTags)
+	}
+}
+
+func TestRateGameTags(t *testing.T) {
+	tags := []string{
+		"Action", "Hack and Slash", "Beat 'em up", "Fighting", "Action-Adventure",
+		"Souls-like", "Dark Fantasy", "Atmospheric", "Singleplayer", "Controller",
+		"3D", "Third Person", "Difficult", "Story Rich", "Exploration",
+	}
+
+	rating, _ := RateGameTags([]string{"Action"}, tags)
+	if rating.Score != 100 {
+		t.Errorf("expected a perfect tags score, got %d: %+v", rating.Score, rating.Checklist)
+	}
+
+	rating, _ = RateGameTags([]string{"Action"}, []string{"Puzzle"})
+	if rating.Score >= 50 {
+		t.Errorf("expected a low tags score for a single tag, got %d", rating.Score)
+	}
+}
+
+func TestGenreTags(t *testing.T) {
+	cases := []struct {
+		genres   []string
+		tag      string
+		expected bool
+	}{
+		{[]string{"RPG"}, "RPG", true},
+		{[]string{"RPG"}, "Party-Based RPG", true},
+		{[]string{"Strategy"}, "Turn-Based Strategy", true},
+		{[]string{"Casual"}, "Casual", true},
+		{[]string{"Action"}, "Hack and Slash", true},
+		{[]string{"Action"}, "Parkour", true},
+		{[]string{"Visual Novel"}, "Branching Narrative", true},
+		{[]string{"Action"}, "Party-Based RPG", false},
+		{[]string{"Free to Play"}, "Free to Play", false},
+	}
+	for _, c := range cases {
+		targets := Taxonomy().genreTags(c.genres)
+		if targets[normalizeTag(c.tag)] != c.expected {
+			t.Errorf("genres %v: expected %s aligned to be %v", c.genres, c.tag, c.expected)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gdrsapi/pkg/metering"
+	"gdrsapi/pkg/store"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+const usageCollection = "usage"
+
+// ErrBudgetExceeded is returned once the day's spend reaches the ceiling.
+var ErrBudgetExceeded = errors.New("the daily spend limit has been reached")
+
+// Attribution says who and what a call was made for.
+type Attribution struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Template string `json:"template,omitempty"`
+	AppId    string `json:"appId,omitempty"`
+	Client   string `json:"client,omitempty"`
+}
+
+// merge returns a with the non empty fields of b.
+func (a Attribution) merge(b Attribution) Attribution {
+	if b.Endpoint != "" {
+		a.Endpoint = b.Endpoint
+	}
+	if b.Template != "" {
+		a.Template = b.Template
+	}
+	if b.AppId != "" {
+		a.AppId = b.AppId
+	}
+	if b.Client != "" {
+		a.Client = b.Client
+	}
+	return a
+}
+
+// Totals adds up calls. Cost is in US dollars.
+type Totals struct {
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	Neurons      float64 `json:"neurons,omitempty"`
+	Cost         float64 `json:"cost"`
+}
+
+func (t *Totals) add(call metering.Call, cost float64) {
+	t.Calls++
+	t.InputTokens += call.InputTokens
+	t.OutputTokens += call.OutputTokens
+	t.Neurons += call.Neurons
+	t.Cost += cost
+}
+
+// Day is the usage of one UTC day, broken down by attribution.
+type Day struct {
+	Date       string            `json:"date"`
+	Total      Totals            `json:"total"`
+	ByProvider map[string]Totals `json:"byProvider"`
+	ByEndpoint map[string]Totals `json:"byEndpoint"`
+	ByTemplate map[string]Totals `json:"byTemplate"`
+	ByAppId    map[string]Totals `json:"byAppId"`
+	ByClient   map[string]Totals `json:"byClient"`
+}
+
+func newDay(date string) *Day {
+	return &Day{
+		Date:       date,
+		ByProvider: make(map[string]Totals),
+		ByEndpoint: make(map[string]Totals),
+		ByTemplate: make(map[string]Totals),
+		ByAppId:    make(map[string]Totals),
+		ByClient:   make(map[string]Totals),
+	}
+}
+
+func (d *Day) add(a Attribution, call metering.Call, cost float64) {
+	d.Total.add(call, cost)
+	addTo(d.ByProvider, call.Provider, call, cost)
+	addTo(d.ByEndpoint, a.Endpoint, call, cost)
+	addTo(d.ByTemplate, a.Template, call, cost)
+	addTo(d.ByAppId, a.AppId, call, cost)
+	addTo(d.ByClient, a.Client, call, cost)
+}
+
+// clone copies the day so it can be read while calls are still recorded.
+func (d *Day) clone() *Day {
+	c := *d
+	c.ByProvider = maps.Clone(d.ByProvider)
+	c.ByEndpoint = maps.Clone(d.ByEndpoint)
+	c.ByTemplate = maps.Clone(d.ByTemplate)
+	c.ByAppId = maps.Clone(d.ByAppId)
+	c.ByClient = maps.Clone(d.ByClient)
+	return &c
+}
+
+func addTo(m map[string]Totals, key string, call metering.Call, cost float64) {
+	if key == "" {
+		return
+	}
+	t := m[key]
+	t.add(call, cost)
+	m[key] = t
+}
+
+// Prices turns usage into dollars.
+type Prices struct {
+	GeminiInputPerMillion        float64
+	GeminiOutputPerMillion       float64
+	CloudflarePerThousandNeurons float64
+	// CloudflareNeuronsPerCall is used for calls that do not report their
+	// neurons
+	CloudflareNeuronsPerCall float64
+}
+
+// price returns the call with its estimated neurons filled in and its cost.
+func (p Prices) price(call metering.Call) (metering.Call, float64) {
+	switch call.Provider {
+	case metering.ProviderGemini:
+		return call, float64(call.InputTokens)/1e6*p.GeminiInputPerMillion + float64(call.OutputTokens)/1e6*p.GeminiOutputPerMillion
+	case metering.ProviderCloudflare:
+		if call.Neurons == 0 {
+			call.Neurons = p.CloudflareNeuronsPerCall
+		}
+		return call, call.Neurons / 1000 * p.CloudflarePerThousandNeurons
+	}
+	return call, 0
+}
+
+// Tracker keeps the usage of every day in the store and enforces the daily
+// spend limit.
+type Tracker struct {
+	mu         sync.Mutex
+	store      *store.Store
+	prices     Prices
+	dailyLimit float64
+	today      *Day
+	now        func() time.Time
+
+	// saving is taken before mu is released, so days are written in the
+	// order they changed without holding mu during the write.
+	saving sync.Mutex
+}
+
+// NewTracker returns a tracker. A dailyLimit of zero disables the ceiling.
+func NewTracker(store *store.Store, prices Prices, dailyLimit float64) *Tracker {
+	return &Tracker{
+		store:      store,
+		prices:     prices,
+		dailyLimit: dailyLimit,
+		now:        time.Now,
+	}
+}
+
+func dateOf(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// day returns today's usage, loading it when the date changed. A day that
+// fails to load is not kept, so it is loaded again instead of being replaced
+// by an empty one. The caller holds the lock.
+func (t *Tracker) day() (*Day, error) {
+	date := dateOf(t.now())
+	if t.today != nil && t.today.Date == date {
+		return t.today, nil
+	}
+
+	day := newDay(date)
+	if err := t.store.Get(usageCollection, date, day); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("loading usage: %w", err)
+	}
+	t.today = day
+	return day, nil
+}
+
+func (t *Tracker) add(a Attribution, call metering.Call, cost float64) error {
+	t.mu.Lock()
+	day, err := t.day()
+	if err != nil {
+		t.mu.Unlock()
+		return err
+	}
+	day.add(a, call, cost)
+	saved := day.clone()
+	t.saving.Lock()
+	t.mu.Unlock()
+
+	defer t.saving.Unlock()
+	if err := t.store.Put(usageCollection, saved.Date, saved); err != nil {
+		return fmt.Errorf("saving usage: %w", err)
+	}
+	return nil
+}
+
+// Day returns the usage of a date formatted as 2006-01-02.
+func (t *Tracker) Day(date string) (*Day, error) {
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return nil, fmt.Errorf("invalid date %q", date)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if date == dateOf(t.now()) {
+		day, err := t.day()
+		if err != nil {
+			return nil, err
+		}
+		return day.clone(), nil
+	}
+	day := newDay(date)
+	if err := t.store.Get(usageCollection, date, day); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return day, nil
+}
+
+// Today returns the date used for today's usage.
+func (t *Tracker) Today() string {
+	return dateOf(t.now())
+}
+
+// DailyLimit is the spend ceiling in dollars, zero when there is none.
+func (t *Tracker) DailyLimit() float64 {
+	return t.dailyLimit
+}
+
+// CheckBudget returns ErrBudgetExceeded when today's spend reached the
+// daily limit, or the error loading today's usage.
+func (t *Tracker) CheckBudget() error {
+	if t.dailyLimit <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	day, err := t.day()
+	if err != nil {
+		return err
+	}
+	if day.Total.Cost >= t.dailyLimit {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// ResetIn is the time left until the daily usage starts over.
+func (t *Tracker) ResetIn() time.Duration {
+	now := t.now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
+
+// Collector adds up the calls of a single operation, such as one rating.
+type Collector struct {
+	mu     sync.Mutex
+	totals Totals
+}
+
+func (c *Collector) Totals() Totals {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.totals
+}
+
+type ctxKey struct{}
+
+// scope is what a context carries: where to record calls and who they are
+// for.
+type scope struct {
+	tracker     *Tracker
+	attribution Attribution
+	collectors  []*Collector
+}
+
+func scopeFrom(ctx context.Context) scope {
+	s, _ := ctx.Value(ctxKey{}).(scope)
+	return s
+}
+
+// withScope stores s in ctx and makes it the recorder of the calls clients
+// report through metering.Record.
+func withScope(ctx context.Context, s scope) context.Context {
+	return metering.WithRecorder(context.WithValue(ctx, ctxKey{}, s), s)
+}
+
+// WithTracker returns a context whose calls are recorded by t.
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	s := scopeFrom(ctx)
+	s.tracker = t
+	return withScope(ctx, s)
+}
+
+// WithAttribution returns a context whose calls are attributed to a. Empty
+// fields keep the attribution already in ctx.
+func WithAttribution(ctx context.Context, a Attribution) context.Context {
+	s := scopeFrom(ctx)
+	s.attribution = s.attribution.merge(a)
+	return withScope(ctx, s)
+}
+
+// AttributionFrom returns the attribution carried by ctx.
+func AttributionFrom(ctx context.Context) Attribution {
+	return scopeFrom(ctx).attribution
+}
+
+// Collect returns a context that also adds its calls to the returned
+// collector.
+func Collect(ctx context.Context) (context.Context, *Collector) {
+	s := scopeFrom(ctx)
+	c := &Collector{}
+	s.collectors = append(s.collectors[:len(s.collectors):len(s.collectors)], c)
+	return withScope(ctx, s), c
+}
+
+// Record records a call reported through metering.Record with the tracker and
+// collectors of the scope.
+func (s scope) Record(ctx context.Context, call metering.Call) {
+	var cost float64
+	if s.tracker != nil {
+		call, cost = s.tracker.prices.price(call)
+		if err := s.tracker.add(s.attribution, call, cost); err != nil {
+			// losing a usage record must not fail the call that was paid for
+			slog.ErrorContext(ctx, "recording usage", "err", err)
+		}
+	}
+	for _, c := range s.collectors {
+		c.mu.Lock()
+		c.totals.add(call, cost)
+		c.mu.Unlock()
+	}
+}
+
+// CheckBudget checks the daily limit of the tracker carried by ctx.
+func CheckBudget(ctx context.Context) error {
+	if t := scopeFrom(ctx).tracker; t != nil {
+		return t.CheckBudget()
+	}
+	return nil
+}
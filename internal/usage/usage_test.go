@@ -0,0 +1,160 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"gdrsapi/pkg/metering"
+	"gdrsapi/pkg/store"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testPrices = Prices{
+	GeminiInputPerMillion:        0.10,
+	GeminiOutputPerMillion:       0.40,
+	CloudflarePerThousandNeurons: 0.011,
+	CloudflareNeuronsPerCall:     100,
+}
+
+func newTestTracker(s *store.Store, limit float64, now time.Time) *Tracker {
+	tracker := NewTracker(s, testPrices, limit)
+	tracker.now = func() time.Time { return now }
+	return tracker
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRecord(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(s, 0, now)
+
+	ctx := WithTracker(context.Background(), tracker)
+	ctx = WithAttribution(ctx, Attribution{Endpoint: "/getsteamrating", Client: "10.0.0.1"})
+	ctx, spent := Collect(ctx)
+
+	metering.Record(WithAttribution(ctx, Attribution{AppId: "620"}), metering.Call{Provider: metering.ProviderGemini, InputTokens: 1_000_000, OutputTokens: 500_000})
+	metering.Record(WithAttribution(ctx, Attribution{AppId: "620"}), metering.Call{Provider: metering.ProviderCloudflare})
+	// calls outside the collector are only tracked
+	metering.Record(WithAttribution(WithTracker(context.Background(), tracker), Attribution{Endpoint: "/gengamedesigndoc", Template: "basic"}), metering.Call{Provider: metering.ProviderGemini, InputTokens: 2_000_000})
+
+	day, err := tracker.Day("2026-10-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Total.Calls != 3 || !almostEqual(day.Total.Cost, 0.10+0.20+0.0011+0.20) {
+		t.Errorf("unexpected total %+v", day.Total)
+	}
+	if day.ByAppId["620"].Calls != 2 || day.ByEndpoint["/gengamedesigndoc"].InputTokens != 2_000_000 || day.ByTemplate["basic"].Calls != 1 {
+		t.Errorf("unexpected breakdown %+v", day)
+	}
+	if day.ByClient["10.0.0.1"].Calls != 2 || day.ByProvider[metering.ProviderCloudflare].Neurons != 100 {
+		t.Errorf("unexpected breakdown %+v", day)
+	}
+
+	if got := spent.Totals(); got.Calls != 2 || got.Neurons != 100 || !almostEqual(got.Cost, 0.30+0.0011) {
+		t.Errorf("unexpected collected totals %+v", got)
+	}
+
+	// usage survives a restart
+	reloaded, err := newTestTracker(s, 0, now).Day("2026-10-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Total != day.Total {
+		t.Errorf("expected %+v after reload, got %+v", day.Total, reloaded.Total)
+	}
+
+	if _, err := tracker.Day("yesterday"); err == nil {
+		t.Errorf("expected an invalid date error")
+	}
+	if empty, err := tracker.Day("2026-10-18"); err != nil || empty.Total.Calls != 0 {
+		t.Errorf("expected an empty day, got %+v %v", empty, err)
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(s, 0.50, now)
+	ctx := WithTracker(context.Background(), tracker)
+
+	metering.Record(ctx, metering.Call{Provider: metering.ProviderGemini, InputTokens: 4_000_000})
+	if err := CheckBudget(ctx); err != nil {
+		t.Errorf("expected room left, got %v", err)
+	}
+
+	metering.Record(ctx, metering.Call{Provider: metering.ProviderGemini, OutputTokens: 250_000})
+	if err := CheckBudget(ctx); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if tracker.ResetIn() != time.Hour {
+		t.Errorf("expected the budget to reset at midnight, got %s", tracker.ResetIn())
+	}
+
+	// the next day starts from zero
+	tracker.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if err := CheckBudget(ctx); err != nil {
+		t.Errorf("expected a fresh budget, got %v", err)
+	}
+
+	if err := CheckBudget(context.Background()); err != nil {
+		t.Errorf("contexts without a tracker have no limit, got %v", err)
+	}
+}
+
+func TestDayLoadError(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+	// a day file that can not be read must not be replaced by a new day
+	path := filepath.Join(dir, "usage", "2026-10-19.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"date": "2026-10-19", "total": `), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := newTestTracker(s, 1, now)
+	ctx := WithTracker(context.Background(), tracker)
+	metering.Record(ctx, metering.Call{Provider: metering.ProviderGemini, InputTokens: 1_000_000})
+
+	if data, _ := os.ReadFile(path); string(data) != `{"date": "2026-10-19", "total": ` {
+		t.Errorf("expected the unreadable day to be kept, got %s", data)
+	}
+	if _, err := tracker.Day("2026-10-19"); err == nil {
+		t.Error("expected the load error")
+	}
+	if err := CheckBudget(ctx); err == nil || errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected the load error, got %v", err)
+	}
+
+	// once the day loads again calls are counted on top of it
+	if err := os.WriteFile(path, []byte(`{"date": "2026-10-19", "total": {"calls": 3, "cost": 0.5}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	metering.Record(ctx, metering.Call{Provider: metering.ProviderGemini, InputTokens: 1_000_000})
+	day, err := tracker.Day("2026-10-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Total.Calls != 4 || !almostEqual(day.Total.Cost, 0.6) {
+		t.Errorf("unexpected total %+v", day.Total)
+	}
+}
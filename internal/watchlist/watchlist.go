@@ -0,0 +1,413 @@
+package watchlist
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gdrsapi/external/gsheets"
+	"gdrsapi/internal/steamrating"
+	"gdrsapi/internal/usage"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/store"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	collection = "watchlist"
+
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly"
+)
+
+var ErrNotWatched = errors.New("app is not on the watchlist")
+
+var schedules = map[string]time.Duration{
+	ScheduleDaily:  24 * time.Hour,
+	ScheduleWeekly: 7 * 24 * time.Hour,
+}
+
+// Entry is an app watched by a client. It is persisted after every check so
+// the schedule picks up where it left off after a restart.
+type Entry struct {
+	Owner         string     `json:"owner"`
+	AppId         string     `json:"appId"`
+	Url           string     `json:"url"`
+	Schedule      string     `json:"schedule"`
+	ContentHash   string     `json:"contentHash,omitempty"`
+	LastScore     *int       `json:"lastScore,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastCheckedAt *time.Time `json:"lastCheckedAt,omitempty"`
+	LastChangedAt *time.Time `json:"lastChangedAt,omitempty"`
+	NextCheckAt   time.Time  `json:"nextCheckAt"`
+}
+
+// entryKey is the store key of an owner's entry. Owners are api keys or ips,
+// escaping keeps them a valid file name.
+func entryKey(owner string, appId string) string {
+	return url.PathEscape(owner) + "_" + appId
+}
+
+// ChangeEvent is sent when a watched page changed and was rated again.
+type ChangeEvent struct {
+	Owner         string                             `json:"owner"`
+	AppId         string                             `json:"appId"`
+	Url           string                             `json:"url"`
+	PreviousScore int                                `json:"previousScore"`
+	NewScore      int                                `json:"newScore"`
+	Delta         int                                `json:"delta"`
+	PreviousHash  string                             `json:"previousHash"`
+	NewHash       string                             `json:"newHash"`
+	Rating        *steamrating.SteamPageRatingResult `json:"rating"`
+	DetectedAt    time.Time                          `json:"detectedAt"`
+}
+
+// Notifier delivers change events.
+type Notifier interface {
+	Notify(ctx context.Context, event ChangeEvent) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, event ChangeEvent) error
+
+func (f NotifierFunc) Notify(ctx context.Context, event ChangeEvent) error {
+	return f(ctx, event)
+}
+
+// Scraper fetches the content of a steam page.
+type Scraper interface {
+	ScrapeSteamPage(ctx context.Context, steamUrl string) (*steamrating.SteamPageContent, error)
+}
+
+// Rater rates the content of a steam page.
+type Rater interface {
+	GetSteamPageRating(ctx context.Context, spc steamrating.SteamPageContent, se *gsheets.SheetsEntry) (*steamrating.SteamPageRatingResult, error)
+}
+
+type Watchlist struct {
+	logger   *logger.AppLogger
+	store    *store.Store
+	scraper  Scraper
+	rater    Rater
+	notifier Notifier
+	now      func() time.Time
+
+	// serializes entry writes
+	mu sync.Mutex
+}
+
+// NewWatchlist creates the watchlist. notifier can be nil, changes are then
+// only logged.
+func NewWatchlist(logger *logger.AppLogger, store *store.Store, scraper Scraper, rater Rater, notifier Notifier) *Watchlist {
+	return &Watchlist{
+		logger:   logger,
+		store:    store,
+		scraper:  scraper,
+		rater:    rater,
+		notifier: notifier,
+		now:      time.Now,
+	}
+}
+
+// Add watches an app for owner or changes the schedule of an app the owner
+// already watches. New entries are due right away so their baseline is taken
+// on the next tick.
+func (w *Watchlist) Add(owner string, appId string, schedule string) (*Entry, error) {
+	interval, ok := schedules[schedule]
+	if !ok {
+		return nil, fmt.Errorf("unknown schedule %q, use %s or %s", schedule, ScheduleDaily, ScheduleWeekly)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := entryKey(owner, appId)
+	var entry Entry
+	err := w.store.Get(collection, key, &entry)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		now := w.now()
+		entry = Entry{
+			Owner:       owner,
+			AppId:       appId,
+			Url:         steamrating.SteamAppUrl(appId),
+			Schedule:    schedule,
+			CreatedAt:   now,
+			NextCheckAt: now,
+		}
+	case err != nil:
+		return nil, err
+	default:
+		entry.Schedule = schedule
+		if entry.LastCheckedAt != nil {
+			entry.NextCheckAt = entry.LastCheckedAt.Add(interval)
+		}
+	}
+
+	if err := w.store.Put(collection, key, entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Remove stops watching an app of owner.
+func (w *Watchlist) Remove(owner string, appId string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.store.Delete(collection, entryKey(owner, appId))
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNotWatched
+	}
+	return err
+}
+
+// List returns the entries of owner.
+func (w *Watchlist) List(owner string) ([]Entry, error) {
+	_, entries, err := w.all()
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Owner == owner {
+			owned = append(owned, entry)
+		}
+	}
+	return owned, nil
+}
+
+// all returns every entry with its store key.
+func (w *Watchlist) all() ([]string, []Entry, error) {
+	keys, err := w.store.Keys(collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := make([]string, 0, len(keys))
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		var entry Entry
+		if err := w.store.Get(collection, key, &entry); err != nil {
+			// the entry was removed while listing
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			return nil, nil, err
+		}
+		found = append(found, key)
+		entries = append(entries, entry)
+	}
+	return found, entries, nil
+}
+
+// Start checks due entries every interval until ctx is done. Due dates are
+// persisted, so checks missed while the server was down run on the first
+// tick. The ratings are recorded against ctx's usage tracker.
+func (w *Watchlist) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		w.logger.InfoContext(ctx, "starting watchlist scheduler")
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			w.RunDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				w.logger.InfoContext(ctx, "stopped watchlist scheduler")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue checks every entry whose next check is due, one at a time. Due
+// entries wait for the next day while the daily spend limit is reached.
+func (w *Watchlist) RunDue(ctx context.Context) {
+	if err := usage.CheckBudget(ctx); err != nil {
+		w.logger.InfoContext(ctx, "skipping watchlist checks", "err", err)
+		return
+	}
+
+	keys, entries, err := w.all()
+	if err != nil {
+		w.logger.ErrorContext(ctx, "listing watchlist", "err", err)
+		return
+	}
+
+	now := w.now()
+	for i, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if entry.NextCheckAt.After(now) {
+			continue
+		}
+		if _, err := w.check(ctx, keys[i]); err != nil {
+			w.logger.ErrorContext(ctx, "watchlist check failed", "owner", entry.Owner, "appId", entry.AppId, "err", err)
+		}
+	}
+}
+
+// Check scrapes an app of owner and rates it again when the page content
+// changed since the last check. The first check only records the baseline.
+// It returns the change event when one was sent.
+func (w *Watchlist) Check(ctx context.Context, owner string, appId string) (*ChangeEvent, error) {
+	return w.check(ctx, entryKey(owner, appId))
+}
+
+func (w *Watchlist) check(ctx context.Context, key string) (*ChangeEvent, error) {
+	var entry Entry
+	if err := w.store.Get(collection, key, &entry); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNotWatched
+		}
+		return nil, err
+	}
+
+	// scraping and rating take a while, so the lock is only held to save
+	now := w.now()
+	event, err := w.rate(ctx, &entry, now)
+	entry.LastCheckedAt = &now
+	entry.LastError = ""
+	if err != nil {
+		entry.LastError = err.Error()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// keep schedule changes made during the check and drop removed entries
+	var current Entry
+	if getErr := w.store.Get(collection, key, &current); getErr != nil {
+		if errors.Is(getErr, store.ErrNotFound) {
+			return event, err
+		}
+		return nil, getErr
+	}
+	entry.Schedule = current.Schedule
+	entry.NextCheckAt = now.Add(schedules[entry.Schedule])
+
+	if putErr := w.store.Put(collection, key, entry); putErr != nil {
+		return nil, putErr
+	}
+	return event, err
+}
+
+func (w *Watchlist) rate(ctx context.Context, entry *Entry, now time.Time) (*ChangeEvent, error) {
+	spc, err := w.scraper.ScrapeSteamPage(ctx, entry.Url)
+	if err != nil {
+		return nil, fmt.Errorf("scraping steam page: %w", err)
+	}
+
+	hash, err := contentHash(spc)
+	if err != nil {
+		return nil, err
+	}
+
+	// unchanged pages keep their last rating
+	if hash == entry.ContentHash && entry.LastScore != nil {
+		w.logger.InfoContext(ctx, "watchlist app is unchanged", "appId", entry.AppId)
+		return nil, nil
+	}
+
+	se := &gsheets.SheetsEntry{
+		Title:      spc.Title,
+		AppId:      entry.AppId,
+		Url:        entry.Url,
+		PromptType: "watchlist",
+	}
+	rating, err := w.rater.GetSteamPageRating(ctx, *spc, se)
+	if err != nil {
+		return nil, fmt.Errorf("rating steam page: %w", err)
+	}
+
+	previousHash, previousScore := entry.ContentHash, entry.LastScore
+	score := rating.FinalWeightedScore
+	entry.ContentHash = hash
+	entry.LastScore = &score
+
+	if previousScore == nil {
+		w.logger.InfoContext(ctx, "watchlist baseline recorded", "appId", entry.AppId, "score", score)
+		return nil, nil
+	}
+
+	entry.LastChangedAt = &now
+	event := &ChangeEvent{
+		Owner:         entry.Owner,
+		AppId:         entry.AppId,
+		Url:           entry.Url,
+		PreviousScore: *previousScore,
+		NewScore:      score,
+		Delta:         score - *previousScore,
+		PreviousHash:  previousHash,
+		NewHash:       hash,
+		Rating:        rating,
+		DetectedAt:    now,
+	}
+
+	w.logger.InfoContext(ctx, "watchlist app changed", "appId", entry.AppId, "previousScore", event.PreviousScore, "newScore", event.NewScore)
+	if w.notifier != nil {
+		if err := w.notifier.Notify(ctx, *event); err != nil {
+			return event, fmt.Errorf("sending change event: %w", err)
+		}
+	}
+	return event, nil
+}
+
+// contentHash hashes the parts of a page the rating looks at. Steam lists
+// genres and tags in a varying order, so they are sorted, and image urls
+// lose their cache busting query. Highlight images keep their order since
+// only the first ones are rated.
+func contentHash(spc *steamrating.SteamPageContent) (string, error) {
+	content := struct {
+		Title            string   `json:"title"`
+		Description      string   `json:"description"`
+		AboutGameText    string   `json:"aboutGameText"`
+		Genres           []string `json:"genres"`
+		Tags             []string `json:"tags"`
+		CapsuleImgUrl    string   `json:"capsuleImgUrl"`
+		HighlightImgUrls []string `json:"highlightImgUrls"`
+	}{
+		Title:         strings.TrimSpace(spc.Title),
+		Description:   strings.TrimSpace(spc.CapsuleDesc),
+		AboutGameText: strings.TrimSpace(spc.AboutGameText),
+		Genres:        sortedCopy(spc.Genres),
+		Tags:          sortedCopy(spc.Tags),
+		CapsuleImgUrl: withoutQuery(spc.CapsuleImgUrl),
+	}
+	for _, u := range spc.HighlightImgUrls {
+		content.HighlightImgUrls = append(content.HighlightImgUrls, withoutQuery(u))
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("hashing steam page content: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sortedCopy(values []string) []string {
+	sorted := make([]string, 0, len(values))
+	for _, v := range values {
+		sorted = append(sorted, strings.TrimSpace(v))
+	}
+	slices.Sort(sorted)
+	return sorted
+}
+
+func withoutQuery(imgUrl string) string {
+	u, _, _ := strings.Cut(imgUrl, "?")
+	return u
+}
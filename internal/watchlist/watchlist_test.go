@@ -0,0 +1,306 @@
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"gdrsapi/external/gsheets"
+	"gdrsapi/internal/steamrating"
+	"gdrsapi/pkg/logger"
+	"gdrsapi/pkg/store"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeScraper serves page content by url and counts the scrapes.
+type fakeScraper struct {
+	pages   map[string]steamrating.SteamPageContent
+	scrapes map[string]int
+}
+
+func (f *fakeScraper) ScrapeSteamPage(ctx context.Context, steamUrl string) (*steamrating.SteamPageContent, error) {
+	f.scrapes[steamUrl]++
+	spc, ok := f.pages[steamUrl]
+	if !ok {
+		return nil, errors.New("page not found")
+	}
+	return &spc, nil
+}
+
+// fakeRater scores a page by the length of its description.
+type fakeRater struct {
+	ratings int
+}
+
+func (f *fakeRater) GetSteamPageRating(ctx context.Context, spc steamrating.SteamPageContent, se *gsheets.SheetsEntry) (*steamrating.SteamPageRatingResult, error) {
+	f.ratings++
+	return &steamrating.SteamPageRatingResult{FinalWeightedScore: len(spc.CapsuleDesc)}, nil
+}
+
+type testWatchlist struct {
+	*Watchlist
+	scraper *fakeScraper
+	rater   *fakeRater
+	events  []ChangeEvent
+	clock   time.Time
+}
+
+func newTestWatchlist(t *testing.T, s *store.Store, now time.Time) *testWatchlist {
+	t.Helper()
+	tw := &testWatchlist{
+		scraper: &fakeScraper{pages: make(map[string]steamrating.SteamPageContent), scrapes: make(map[string]int)},
+		rater:   &fakeRater{},
+		clock:   now,
+	}
+	notifier := NotifierFunc(func(ctx context.Context, event ChangeEvent) error {
+		tw.events = append(tw.events, event)
+		return nil
+	})
+	tw.Watchlist = NewWatchlist(logger.New(logger.Options{Output: io.Discard}), s, tw.scraper, tw.rater, notifier)
+	tw.now = func() time.Time { return tw.clock }
+	return tw
+}
+
+func (tw *testWatchlist) setPage(appId string, spc steamrating.SteamPageContent) {
+	tw.scraper.pages[steamrating.SteamAppUrl(appId)] = spc
+}
+
+func (tw *testWatchlist) scrapes(appId string) int {
+	return tw.scraper.scrapes[steamrating.SteamAppUrl(appId)]
+}
+
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+var portal2 = steamrating.SteamPageContent{
+	Title:            "Portal 2",
+	CapsuleDesc:      "The sequel to the award winning Portal.",
+	AboutGameText:    "Think with portals.",
+	Genres:           []string{"Action", "Adventure"},
+	Tags:             []string{"Puzzle", "Co-op", "First-Person"},
+	CapsuleImgUrl:    "https://cdn.steam.example/620/header.jpg?t=1",
+	HighlightImgUrls: []string{"https://cdn.steam.example/620/1.jpg?t=1", "https://cdn.steam.example/620/2.jpg?t=1"},
+	AboutGameLinks:   []string{"https://example.com/a"},
+}
+
+func TestContentHash(t *testing.T) {
+	cases := []struct {
+		name    string
+		change  func(spc *steamrating.SteamPageContent)
+		changed bool
+	}{
+		{"same content", func(spc *steamrating.SteamPageContent) {}, false},
+		{"tags reordered", func(spc *steamrating.SteamPageContent) {
+			spc.Tags = []string{"First-Person", "Puzzle", "Co-op"}
+		}, false},
+		{"genres reordered", func(spc *steamrating.SteamPageContent) {
+			spc.Genres = []string{"Adventure", "Action"}
+		}, false},
+		{"cache busted images", func(spc *steamrating.SteamPageContent) {
+			spc.CapsuleImgUrl = "https://cdn.steam.example/620/header.jpg?t=2"
+			spc.HighlightImgUrls = []string{"https://cdn.steam.example/620/1.jpg?t=2", "https://cdn.steam.example/620/2.jpg?t=2"}
+		}, false},
+		{"surrounding whitespace", func(spc *steamrating.SteamPageContent) {
+			spc.CapsuleDesc = " " + spc.CapsuleDesc + "\n"
+		}, false},
+		{"unrated links", func(spc *steamrating.SteamPageContent) {
+			spc.AboutGameLinks = []string{"https://example.com/b"}
+		}, false},
+		{"description", func(spc *steamrating.SteamPageContent) {
+			spc.CapsuleDesc = "A new description."
+		}, true},
+		{"tag added", func(spc *steamrating.SteamPageContent) {
+			spc.Tags = append(spc.Tags, "Funny")
+		}, true},
+		{"tag replaced", func(spc *steamrating.SteamPageContent) {
+			spc.Tags = []string{"Puzzle", "Co-op", "Singleplayer"}
+		}, true},
+		{"highlights reordered", func(spc *steamrating.SteamPageContent) {
+			spc.HighlightImgUrls = []string{"https://cdn.steam.example/620/2.jpg", "https://cdn.steam.example/620/1.jpg"}
+		}, true},
+		{"new capsule", func(spc *steamrating.SteamPageContent) {
+			spc.CapsuleImgUrl = "https://cdn.steam.example/620/header_v2.jpg"
+		}, true},
+	}
+
+	base, err := contentHash(&portal2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		spc := portal2
+		spc.Tags = append([]string(nil), portal2.Tags...)
+		c.change(&spc)
+
+		hash, err := contentHash(&spc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (hash != base) != c.changed {
+			t.Errorf("%s: expected changed to be %v", c.name, c.changed)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		schedule string
+		// schedule set after the first check, if any
+		reschedule string
+		expected   time.Time
+	}{
+		{"daily", ScheduleDaily, "", start.Add(24 * time.Hour)},
+		{"weekly", ScheduleWeekly, "", start.Add(7 * 24 * time.Hour)},
+		{"daily to weekly", ScheduleDaily, ScheduleWeekly, start.Add(7 * 24 * time.Hour)},
+		{"weekly to daily", ScheduleWeekly, ScheduleDaily, start.Add(24 * time.Hour)},
+	}
+	for _, c := range cases {
+		w := newTestWatchlist(t, newTestStore(t), start)
+		w.setPage("620", portal2)
+
+		entry, err := w.Add("10.0.0.1", "620", c.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !entry.NextCheckAt.Equal(start) {
+			t.Errorf("%s: expected a new entry to be due right away, got %s", c.name, entry.NextCheckAt)
+		}
+
+		if _, err := w.Check(context.Background(), "10.0.0.1", "620"); err != nil {
+			t.Fatal(err)
+		}
+
+		// rescheduling later keeps the last check as the reference
+		w.clock = start.Add(time.Hour)
+		if c.reschedule != "" {
+			if _, err := w.Add("10.0.0.1", "620", c.reschedule); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		entries, err := w.List("10.0.0.1")
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("%s: expected 1 entry, got %v, %v", c.name, entries, err)
+		}
+		if !entries[0].NextCheckAt.Equal(c.expected) {
+			t.Errorf("%s: expected the next check at %s, got %s", c.name, c.expected, entries[0].NextCheckAt)
+		}
+	}
+
+	w := newTestWatchlist(t, newTestStore(t), start)
+	if _, err := w.Add("10.0.0.1", "620", "hourly"); err == nil {
+		t.Error("expected an unknown schedule to fail")
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	w := newTestWatchlist(t, s, start)
+	w.setPage("620", portal2)
+	w.setPage("400", steamrating.SteamPageContent{Title: "Portal", CapsuleDesc: "Think with portals."})
+	if _, err := w.Add("10.0.0.1", "620", ScheduleDaily); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("key:abc", "400", ScheduleWeekly); err != nil {
+		t.Fatal(err)
+	}
+
+	// both are due right away and only record their baseline
+	w.RunDue(ctx)
+	if w.scrapes("620") != 1 || w.scrapes("400") != 1 || w.rater.ratings != 2 || len(w.events) != 0 {
+		t.Fatalf("expected 2 baselines without events, got %d ratings and %v", w.rater.ratings, w.events)
+	}
+
+	// nothing is due an hour later
+	w.clock = start.Add(time.Hour)
+	w.RunDue(ctx)
+	if w.scrapes("620") != 1 || w.scrapes("400") != 1 {
+		t.Errorf("expected no checks before the entries are due")
+	}
+
+	// the server restarts three days later with a changed page, the missed
+	// daily checks run once and the weekly entry is not due yet
+	restarted := newTestWatchlist(t, s, start.Add(3*24*time.Hour))
+	changed := portal2
+	changed.CapsuleDesc = "The sequel to Portal."
+	restarted.setPage("620", changed)
+	restarted.setPage("400", steamrating.SteamPageContent{Title: "Portal", CapsuleDesc: "Think with portals."})
+
+	restarted.RunDue(ctx)
+	if restarted.scrapes("620") != 1 || restarted.scrapes("400") != 0 {
+		t.Errorf("expected only the daily entry to catch up, got %v", restarted.scraper.scrapes)
+	}
+	if len(restarted.events) != 1 {
+		t.Fatalf("expected a change event, got %v", restarted.events)
+	}
+	event := restarted.events[0]
+	expectedDelta := len(changed.CapsuleDesc) - len(portal2.CapsuleDesc)
+	if event.Owner != "10.0.0.1" || event.AppId != "620" || event.Delta != expectedDelta {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	entries, err := restarted.List("10.0.0.1")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %v, %v", entries, err)
+	}
+	if expected := start.Add(4 * 24 * time.Hour); !entries[0].NextCheckAt.Equal(expected) {
+		t.Errorf("expected the next check at %s, got %s", expected, entries[0].NextCheckAt)
+	}
+
+	// an unchanged page is not rated again
+	restarted.clock = start.Add(4 * 24 * time.Hour)
+	ratings := restarted.rater.ratings
+	restarted.RunDue(ctx)
+	if restarted.scrapes("620") != 2 || restarted.rater.ratings != ratings {
+		t.Errorf("expected the unchanged page to be scraped but not rated")
+	}
+
+	// a failing scrape is recorded and retried on the next schedule
+	delete(restarted.scraper.pages, steamrating.SteamAppUrl("620"))
+	restarted.clock = start.Add(5 * 24 * time.Hour)
+	restarted.RunDue(ctx)
+	entries, _ = restarted.List("10.0.0.1")
+	if entries[0].LastError == "" || !entries[0].NextCheckAt.Equal(start.Add(6*24*time.Hour)) {
+		t.Errorf("expected the error to be kept until the next check, got %+v", entries[0])
+	}
+}
+
+func TestOwners(t *testing.T) {
+	w := newTestWatchlist(t, newTestStore(t), time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+
+	for _, owner := range []string{"key:abc", "key:def", "2001:db8::1"} {
+		if _, err := w.Add(owner, "620", ScheduleDaily); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := w.List("key:abc")
+	if err != nil || len(entries) != 1 || entries[0].Owner != "key:abc" {
+		t.Errorf("expected only the key's entry, got %v, %v", entries, err)
+	}
+
+	if err := w.Remove("key:abc", "620"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Remove("key:abc", "620"); !errors.Is(err, ErrNotWatched) {
+		t.Errorf("expected ErrNotWatched, got %v", err)
+	}
+	if entries, _ := w.List("key:def"); len(entries) != 1 {
+		t.Errorf("expected other owners to keep their entry, got %v", entries)
+	}
+	if entries, _ := w.List("2001:db8::1"); len(entries) != 1 {
+		t.Errorf("expected the ipv6 owner to keep its entry, got %v", entries)
+	}
+}
@@ -0,0 +1,282 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gdrsapi/pkg/logger"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventRatingCompleted    = "rating.completed"
+	EventRatingFailed       = "rating.failed"
+	EventDesignDocGenerated = "designdoc.generated"
+	EventWatchlistChanged   = "watchlist.changed"
+)
+
+const (
+	SignatureHeader = "X-Gdrs-Signature"
+	TimestampHeader = "X-Gdrs-Timestamp"
+	EventHeader     = "X-Gdrs-Event"
+	DeliveryHeader  = "X-Gdrs-Delivery"
+)
+
+const (
+	maxAttempts        = 5
+	defaultBackoff     = 2 * time.Second
+	deliveryTimeout    = 10 * time.Second
+	signatureTolerance = 5 * time.Minute
+	signaturePrefix    = "sha256="
+)
+
+// Event is the json body of every webhook delivery.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Endpoint receives the events listed in Events, or every event when Events
+// is empty.
+type Endpoint struct {
+	Url    string
+	Events []string
+}
+
+func (e Endpoint) wants(eventType string) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, eventType)
+}
+
+// DeadLetter is written for deliveries that failed every attempt.
+type DeadLetter struct {
+	Url      string    `json:"url"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// Dispatcher delivers signed events to the configured endpoints in the
+// background. Failed deliveries are retried with exponential backoff and then
+// appended to the dead letter file as ndjson.
+type Dispatcher struct {
+	logger         *logger.AppLogger
+	endpoints      []Endpoint
+	secret         string
+	deadLetterPath string
+	httpClient     *http.Client
+	backoff        time.Duration
+
+	deadLetterMu sync.Mutex
+	wg           sync.WaitGroup
+}
+
+// ErrMissingSecret is returned for endpoints configured without a secret to
+// sign their deliveries with.
+var ErrMissingSecret = errors.New("webhook endpoints need a secret to sign deliveries")
+
+func NewDispatcher(logger *logger.AppLogger, endpoints []Endpoint, secret string, deadLetterPath string) (*Dispatcher, error) {
+	if len(endpoints) > 0 && secret == "" {
+		return nil, ErrMissingSecret
+	}
+
+	return &Dispatcher{
+		logger:         logger,
+		endpoints:      endpoints,
+		secret:         secret,
+		deadLetterPath: deadLetterPath,
+		httpClient: &http.Client{
+			Timeout: deliveryTimeout,
+		},
+		backoff: defaultBackoff,
+	}, nil
+}
+
+// Dispatch sends the event to every endpoint subscribed to its type without
+// blocking the caller. Deliveries keep the values of ctx, such as the request
+// id, but are not cancelled with it.
+func (d *Dispatcher) Dispatch(ctx context.Context, eventType string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	event := Event{
+		ID:        newEventId(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "encoding webhook event", "event", eventType, "err", err)
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	for _, endpoint := range d.endpoints {
+		if !endpoint.wants(eventType) {
+			continue
+		}
+
+		d.wg.Add(1)
+		go func(url string) {
+			defer d.wg.Done()
+			d.deliver(ctx, url, event, body)
+		}(endpoint.Url)
+	}
+}
+
+// Wait blocks until every delivery in flight has finished.
+func (d *Dispatcher) Wait() {
+	if d == nil {
+		return
+	}
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, url string, event Event, body []byte) {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = d.post(ctx, url, event, body); err == nil {
+			d.logger.InfoContext(ctx, "delivered webhook", "event", event.Type, "delivery", event.ID, "url", url)
+			return
+		}
+
+		d.logger.ErrorContext(ctx, "delivering webhook", "delivery", event.ID, "url", url, "attempt", attempt, "err", err)
+		if attempt < maxAttempts {
+			time.Sleep(d.backoff * time.Duration(1<<(attempt-1)))
+		}
+	}
+
+	d.writeDeadLetter(ctx, DeadLetter{
+		Url:      url,
+		Event:    event,
+		Attempts: maxAttempts,
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+	})
+}
+
+func (d *Dispatcher) post(ctx context.Context, url string, event Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(d.secret, timestamp, body))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Dispatcher) writeDeadLetter(ctx context.Context, dl DeadLetter) {
+	if d.deadLetterPath == "" {
+		return
+	}
+
+	line, err := json.Marshal(dl)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "encoding dead letter", "err", err)
+		return
+	}
+
+	d.deadLetterMu.Lock()
+	defer d.deadLetterMu.Unlock()
+
+	f, err := os.OpenFile(d.deadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "opening dead letter file", "err", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		d.logger.ErrorContext(ctx, "writing dead letter", "err", err)
+	}
+}
+
+// Sign returns the signature header value for a body sent at timestamp. The
+// timestamp is signed with the body so old deliveries can not be replayed.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's signature and that its timestamp is recent.
+func Verify(secret string, timestamp string, body []byte, signature string) error {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+
+	age := time.Since(time.Unix(sent, 0))
+	if age > signatureTolerance || age < -signatureTolerance {
+		return fmt.Errorf("timestamp is outside the %s tolerance", signatureTolerance)
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+func newEventId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating event id: %s", err))
+	}
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// ParseEndpoints reads a comma separated list of endpoint urls. An url can be
+// followed by the events it subscribes to, separated by |, as in
+// "https://example.com/hook|rating.completed|rating.failed".
+func ParseEndpoints(spec string) []Endpoint {
+	var endpoints []Endpoint
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), "|")
+		if parts[0] == "" {
+			continue
+		}
+
+		endpoint := Endpoint{Url: parts[0]}
+		for _, event := range parts[1:] {
+			if event = strings.TrimSpace(event); event != "" {
+				endpoint.Events = append(endpoint.Events, event)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gdrsapi/pkg/logger"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatchSignsAndRetries(t *testing.T) {
+	const secret = "test-secret"
+	var attempts atomic.Int32
+	received := make(chan Event, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)); err != nil {
+			t.Errorf("invalid signature: %s", err)
+		}
+
+		// fail the first delivery to exercise the retry
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var event Event
+		json.Unmarshal(body, &event)
+		received <- event
+	}))
+	defer srv.Close()
+
+	d, err := NewDispatcher(logger.NewAppLogger(), []Endpoint{{Url: srv.URL}}, secret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.backoff = time.Millisecond
+	d.Dispatch(context.Background(), EventRatingCompleted, map[string]string{"appId": "620"})
+	d.Wait()
+
+	select {
+	case event := <-received:
+		if event.Type != EventRatingCompleted {
+			t.Errorf("unexpected event type %s", event.Type)
+		}
+	default:
+		t.Fatal("event was not delivered")
+	}
+
+	if attempts.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestNewDispatcher(t *testing.T) {
+	endpoints := []Endpoint{{Url: "https://example.com/hook"}}
+	if _, err := NewDispatcher(logger.NewAppLogger(), endpoints, "", ""); !errors.Is(err, ErrMissingSecret) {
+		t.Errorf("expected ErrMissingSecret, got %v", err)
+	}
+	if _, err := NewDispatcher(logger.NewAppLogger(), nil, "", ""); err != nil {
+		t.Errorf("expected no secret to be needed without endpoints, got %v", err)
+	}
+}
+
+func TestDispatchDeadLetter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	deadLetterPath := filepath.Join(t.TempDir(), "dead_letter.ndjson")
+	endpoints := []Endpoint{
+		{Url: srv.URL, Events: []string{EventRatingFailed}},
+		{Url: srv.URL, Events: []string{EventWatchlistChanged}},
+	}
+
+	d, err := NewDispatcher(logger.NewAppLogger(), endpoints, "test-secret", deadLetterPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.backoff = time.Millisecond
+	d.Dispatch(context.Background(), EventRatingFailed, map[string]string{"appId": "620"})
+	d.Wait()
+
+	data, err := os.ReadFile(deadLetterPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dl DeadLetter
+	if err := json.Unmarshal(data, &dl); err != nil {
+		t.Fatalf("expected a single dead letter line, got %q", data)
+	}
+	if dl.Attempts != maxAttempts || dl.Event.Type != EventRatingFailed {
+		t.Errorf("unexpected dead letter %+v", dl)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	if err := Verify("secret", now, body, Sign("secret", now, body)); err != nil {
+		t.Errorf("expected a valid signature, got %s", err)
+	}
+	if err := Verify("other", now, body, Sign("secret", now, body)); err == nil {
+		t.Error("expected a signature made with another secret to fail")
+	}
+	if err := Verify("secret", old, body, Sign("secret", old, body)); err == nil {
+		t.Error("expected an old timestamp to fail")
+	}
+}
+
+func TestParseEndpoints(t *testing.T) {
+	endpoints := ParseEndpoints("https://a.test/hook, https://b.test/hook|rating.completed|rating.failed,")
+
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %+v", endpoints)
+	}
+	if endpoints[0].Url != "https://a.test/hook" || len(endpoints[0].Events) != 0 {
+		t.Errorf("unexpected endpoint %+v", endpoints[0])
+	}
+	if !endpoints[1].wants(EventRatingFailed) || endpoints[1].wants(EventDesignDocGenerated) {
+		t.Errorf("unexpected event filter %+v", endpoints[1])
+	}
+}
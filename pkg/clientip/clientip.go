@@ -0,0 +1,130 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrusted parses comma separated networks such as
+// "10.0.0.0/8,173.245.48.0/20". A single ip is a network of its own.
+func ParseTrusted(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Resolver finds the ip of the client behind a request. The forwarding
+// headers are anyone's to set, so they are only read when the request comes
+// from a trusted proxy.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+func NewResolver(trusted []netip.Prefix) *Resolver {
+	return &Resolver{trusted: trusted}
+}
+
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, p := range r.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client ip of a request. Behind a trusted proxy it is
+// taken from CF-Connecting-IP, X-Real-IP or else the last address of
+// X-Forwarded-For that is not itself a trusted proxy.
+func (r *Resolver) Resolve(req *http.Request) string {
+	remote, ok := parseAddr(req.RemoteAddr)
+	if !ok {
+		return req.RemoteAddr
+	}
+	if !r.isTrusted(remote) {
+		return remote.String()
+	}
+
+	for _, header := range []string{"CF-Connecting-IP", "X-Real-IP"} {
+		if addr, ok := parseAddr(req.Header.Get(header)); ok {
+			return addr.String()
+		}
+	}
+
+	// each proxy appends the address it got the request from, so the
+	// client is the closest one that is not a proxy of ours
+	var hops []string
+	for _, v := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			// an address that can not be read could be anything
+			break
+		}
+		if i == 0 || !r.isTrusted(addr) {
+			return addr.String()
+		}
+	}
+	return remote.String()
+}
+
+// parseAddr reads an ip with or without a port.
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+type ctxKey struct{}
+
+// Handler resolves the client ip of every request once, for FromRequest to
+// return it.
+func (r *Resolver) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := context.WithValue(req.Context(), ctxKey{}, r.Resolve(req))
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// FromRequest returns the ip resolved by Handler, or the remote address of
+// requests that did not go through it.
+func FromRequest(req *http.Request) string {
+	if ip, ok := req.Context().Value(ctxKey{}).(string); ok {
+		return ip
+	}
+	if addr, ok := parseAddr(req.RemoteAddr); ok {
+		return addr.String()
+	}
+	return req.RemoteAddr
+}
@@ -0,0 +1,81 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrusted(t *testing.T) {
+	prefixes, err := ParseTrusted("10.0.0.0/8, 192.168.1.7,2400:cb00::/32,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefixes) != 3 || prefixes[1].String() != "192.168.1.7/32" {
+		t.Errorf("unexpected prefixes %v", prefixes)
+	}
+
+	for _, spec := range []string{"proxy", "10.0.0.0/33"} {
+		if _, err := ParseTrusted(spec); err == nil {
+			t.Errorf("expected ParseTrusted(%q) to fail", spec)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	trusted, err := ParseTrusted("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewResolver(trusted)
+
+	cases := []struct {
+		name     string
+		remote   string
+		headers  map[string][]string
+		expected string
+	}{
+		{"direct", "203.0.113.9:5123", nil, "203.0.113.9"},
+		{"untrusted forwarded for", "203.0.113.9:5123", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.9"},
+		{"untrusted cloudflare", "203.0.113.9:5123", map[string][]string{"CF-Connecting-IP": {"198.51.100.1"}}, "203.0.113.9"},
+		{"cloudflare", "10.0.0.2:5123", map[string][]string{"CF-Connecting-IP": {"198.51.100.1"}, "X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.1"},
+		{"real ip", "10.0.0.2:5123", map[string][]string{"X-Real-IP": {"198.51.100.1"}}, "198.51.100.1"},
+		{"spoofed forwarded for", "10.0.0.2:5123", map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1", "10.0.0.3"}}, "198.51.100.1"},
+		{"only proxies", "10.0.0.2:5123", map[string][]string{"X-Forwarded-For": {"10.0.0.4, 10.0.0.3"}}, "10.0.0.4"},
+		{"garbage", "10.0.0.2:5123", map[string][]string{"X-Forwarded-For": {"not an ip"}}, "10.0.0.2"},
+		{"no headers", "10.0.0.2:5123", nil, "10.0.0.2"},
+		{"ipv6", "[2001:db8::1]:5123", nil, "2001:db8::1"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = c.remote
+		for k, values := range c.headers {
+			for _, v := range values {
+				req.Header.Add(k, v)
+			}
+		}
+		if got := r.Resolve(req); got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	trusted, _ := ParseTrusted("10.0.0.0/8")
+	var got string
+	h := NewResolver(trusted).Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = FromRequest(req)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:5123"
+	req.Header.Set("X-Real-IP", "198.51.100.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "198.51.100.1" {
+		t.Errorf("expected the resolved ip, got %s", got)
+	}
+
+	if ip := FromRequest(req); ip != "10.0.0.2" {
+		t.Errorf("expected the remote address outside the handler, got %s", ip)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 
 	"github.com/joho/godotenv"
@@ -14,6 +16,40 @@ type Config struct {
 	GeminiApiKey        string
 	GoogleSACred        string
 	Environment         string
+
+	// Optional settings, defaults are applied when they are not set
+	BatchConcurrency    int
+	BatchRatePerMinute  int
+	BatchMaxItems       int
+	DataDir             string
+	WatchlistWebhookUrl string
+	WebhookUrls         string
+	WebhookSecret       string
+	WebhookDeadLetter   string
+	TemplatesDir        string
+
+	// Usage prices in US dollars and the daily spend limit, zero disables it
+	GeminiInputCostPerMillion        float64
+	GeminiOutputCostPerMillion       float64
+	CloudflareCostPerThousandNeurons float64
+	CloudflareNeuronsPerCall         float64
+	DailySpendLimit                  float64
+
+	// AdminToken guards the api key admin endpoints, they are off without it
+	AdminToken      string
+	ApiKeyRateLimit int
+
+	// RateLimitPolicies replaces default policies, such as "rating=50/24h"
+	RateLimitPolicies string
+	RateLimitStore    string
+
+	// TrustedProxies are the networks whose forwarding headers are believed
+	TrustedProxies string
+
+	// LogLevel is debug, info, warn or error, prompts are only logged at
+	// debug. LogFormat is json or text
+	LogLevel  string
+	LogFormat string
 }
 
 var (
@@ -50,6 +86,7 @@ func LoadConfig() error {
 			GeminiApiKey:        geminiApiKey,
 			GoogleSACred:        googleSACred,
 		}
+		loadOptionalConfig(cfg)
 		return nil
 	}
 
@@ -70,6 +107,7 @@ func LoadConfig() error {
 		GoogleSACred:        os.Getenv("GOOGLE_SA_CRED"),
 		Environment:         os.Getenv("ENVIRONMENT"),
 	}
+	loadOptionalConfig(cfg)
 
 	// Required env vars
 	if cfg.CloudflareAccountId == "" {
@@ -90,3 +128,50 @@ func LoadConfig() error {
 
 	return nil
 }
+
+func loadOptionalConfig(c *Config) {
+	c.BatchConcurrency = getEnvInt("BATCH_CONCURRENCY", 2)
+	c.BatchRatePerMinute = getEnvInt("BATCH_RATE_PER_MINUTE", 10)
+	c.BatchMaxItems = getEnvInt("BATCH_MAX_ITEMS", 50)
+	c.DataDir = getEnvString("DATA_DIR", "data")
+	c.WatchlistWebhookUrl = os.Getenv("WATCHLIST_WEBHOOK_URL")
+	c.WebhookUrls = os.Getenv("WEBHOOK_URLS")
+	c.WebhookSecret = os.Getenv("WEBHOOK_SECRET")
+	c.WebhookDeadLetter = getEnvString("WEBHOOK_DEAD_LETTER_FILE", filepath.Join(c.DataDir, "webhooks_dead_letter.ndjson"))
+	c.TemplatesDir = getEnvString("TEMPLATES_DIR", filepath.Join(c.DataDir, "templates"))
+	c.GeminiInputCostPerMillion = getEnvFloat("GEMINI_INPUT_COST_PER_MILLION", 0.10)
+	c.GeminiOutputCostPerMillion = getEnvFloat("GEMINI_OUTPUT_COST_PER_MILLION", 0.40)
+	c.CloudflareCostPerThousandNeurons = getEnvFloat("CLOUDFLARE_COST_PER_THOUSAND_NEURONS", 0.011)
+	c.CloudflareNeuronsPerCall = getEnvFloat("CLOUDFLARE_NEURONS_PER_CALL", 0)
+	c.DailySpendLimit = getEnvFloat("DAILY_SPEND_LIMIT", 0)
+	c.AdminToken = os.Getenv("ADMIN_TOKEN")
+	c.ApiKeyRateLimit = getEnvInt("API_KEY_RATE_LIMIT", 60)
+	c.RateLimitPolicies = os.Getenv("RATE_LIMIT_POLICIES")
+	c.RateLimitStore = getEnvString("RATE_LIMIT_STORE", "file")
+	c.TrustedProxies = os.Getenv("TRUSTED_PROXIES")
+	c.LogLevel = getEnvString("LOG_LEVEL", "info")
+	c.LogFormat = getEnvString("LOG_FORMAT", "json")
+}
+
+func getEnvInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+func getEnvFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
+
+func getEnvString(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,61 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDocumentBytes(t *testing.T) {
+	d := New()
+	d.Heading(1, "Dream Architect")
+	d.Heading(2, "Core Mechanics")
+	d.Paragraph("Puzzles & <dreams>\nsecond line")
+	d.Bullet("Reality Bending")
+	d.BoldParagraph("Time Dilation: ", "slow down sections of the dream")
+
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+
+		// every part must be well formed xml for word to open the file
+		dec := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s is not valid xml: %s", f.Name, err)
+			}
+		}
+
+		if f.Name == "word/document.xml" {
+			document = string(content)
+		}
+	}
+
+	for _, want := range []string{"Dream Architect", "Heading2", "Puzzles &amp; &lt;dreams&gt;", "<w:br/>", `<w:numId w:val="1"/>`, "<w:b/>"} {
+		if !strings.Contains(document, want) {
+			t.Errorf("document.xml is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package jsondiff
+
+import (
+	"gdrsapi/pkg/jsonpath"
+	"reflect"
+	"slices"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Change is one difference between two json values.
+type Change struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Diff lists the changes that turn old into new, both decoded with
+// encoding/json. Objects are compared key by key and lists item by item, any
+// other difference replaces the whole value. Object keys are visited in
+// sorted order and list items by index.
+func Diff(old interface{}, new interface{}) []Change {
+	changes := []Change{}
+	diff(nil, old, new, &changes)
+	return changes
+}
+
+func diff(p jsonpath.Path, old interface{}, new interface{}, changes *[]Change) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			switch {
+			case !inOld:
+				*changes = append(*changes, Change{Op: OpAdd, Path: p.Key(k).String(), New: nv})
+			case !inNew:
+				*changes = append(*changes, Change{Op: OpRemove, Path: p.Key(k).String(), Old: ov})
+			default:
+				diff(p.Key(k), ov, nv, changes)
+			}
+		}
+		return
+
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < max(len(o), len(n)); i++ {
+			switch {
+			case i >= len(o):
+				*changes = append(*changes, Change{Op: OpAdd, Path: p.Index(i).String(), New: n[i]})
+			case i >= len(n):
+				*changes = append(*changes, Change{Op: OpRemove, Path: p.Index(i).String(), Old: o[i]})
+			default:
+				diff(p.Index(i), o[i], n[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Op: OpReplace, Path: p.String(), Old: old, New: new})
+	}
+}
@@ -0,0 +1,28 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	var old, new interface{}
+	json.Unmarshal([]byte(`{"overview": "Old", "coreMechanics": ["Jump", "Dash"], "notes": "x", "uiUx": {"hud": ["Health"]}}`), &old)
+	json.Unmarshal([]byte(`{"overview": "New", "coreMechanics": ["Jump"], "art": "Pixel", "uiUx": {"hud": ["Health", "Map"]}}`), &new)
+
+	want := []Change{
+		{Op: OpAdd, Path: "art", New: "Pixel"},
+		{Op: OpRemove, Path: "coreMechanics[1]", Old: "Dash"},
+		{Op: OpRemove, Path: "notes", Old: "x"},
+		{Op: OpReplace, Path: "overview", Old: "Old", New: "New"},
+		{Op: OpAdd, Path: "uiUx.hud[1]", New: "Map"},
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diff\n got: %+v\nwant: %+v", got, want)
+	}
+
+	if got := Diff(old, old); len(got) != 0 {
+		t.Errorf("expected no changes, got %+v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package jsonpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPath = errors.New("invalid path")
+
+// Step is one part of a path, an object key or an array index.
+type Step struct {
+	Key   string
+	Index int
+	IsKey bool
+}
+
+// Path addresses a value inside decoded json, e.g. mechanics.systems[0].name.
+type Path []Step
+
+// Parse reads a path made of dot separated keys, each followed by any number
+// of [index] parts.
+func Parse(s string) (Path, error) {
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrInvalidPath)
+	}
+
+	var p Path
+	for _, part := range strings.Split(s, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key == "" {
+			return nil, fmt.Errorf("%w %q: missing key", ErrInvalidPath, s)
+		}
+		p = append(p, Step{Key: key, IsKey: true})
+
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			n, err := strconv.Atoi(index)
+			if !ok || err != nil || n < 0 {
+				return nil, fmt.Errorf("%w %q: bad index", ErrInvalidPath, s)
+			}
+			p = append(p, Step{Index: n})
+
+			if after == "" {
+				break
+			}
+			if !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("%w %q: unexpected %q", ErrInvalidPath, s, after)
+			}
+			rest = after[1:]
+		}
+	}
+	return p, nil
+}
+
+func (p Path) String() string {
+	var sb strings.Builder
+	for _, step := range p {
+		if step.IsKey {
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(step.Key)
+			continue
+		}
+		fmt.Fprintf(&sb, "[%d]", step.Index)
+	}
+	return sb.String()
+}
+
+// Key returns the path with an object key added.
+func (p Path) Key(key string) Path {
+	return append(p[:len(p):len(p)], Step{Key: key, IsKey: true})
+}
+
+// Index returns the path with an array index added.
+func (p Path) Index(i int) Path {
+	return append(p[:len(p):len(p)], Step{Index: i})
+}
+
+// Get returns the value at the path inside v, decoded with encoding/json.
+func Get(v interface{}, p Path) (interface{}, error) {
+	for i, step := range p {
+		if step.IsKey {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %s is not an object", ErrInvalidPath, p[:i])
+			}
+			if v, ok = obj[step.Key]; !ok {
+				return nil, fmt.Errorf("%w: %s not found", ErrInvalidPath, p[:i+1])
+			}
+			continue
+		}
+
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not a list", ErrInvalidPath, p[:i])
+		}
+		if step.Index >= len(arr) {
+			return nil, fmt.Errorf("%w: %s is out of range", ErrInvalidPath, p[:i+1])
+		}
+		v = arr[step.Index]
+	}
+	return v, nil
+}
+
+// Set returns v with the value at the path replaced. Missing object keys are
+// added and an index one past the end appends to the list.
+func Set(v interface{}, p Path, value interface{}) (interface{}, error) {
+	return set(v, p, 0, value)
+}
+
+func set(v interface{}, p Path, i int, value interface{}) (interface{}, error) {
+	if i == len(p) {
+		return value, nil
+	}
+
+	step := p[i]
+	if step.IsKey {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			if v != nil {
+				return nil, fmt.Errorf("%w: %s is not an object", ErrInvalidPath, p[:i])
+			}
+			obj = make(map[string]interface{})
+		}
+		child, err := set(obj[step.Key], p, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+		obj[step.Key] = child
+		return obj, nil
+	}
+
+	arr, ok := v.([]interface{})
+	if !ok && v != nil {
+		return nil, fmt.Errorf("%w: %s is not a list", ErrInvalidPath, p[:i])
+	}
+	if step.Index > len(arr) {
+		return nil, fmt.Errorf("%w: %s is out of range", ErrInvalidPath, p[:i+1])
+	}
+
+	var current interface{}
+	if step.Index < len(arr) {
+		current = arr[step.Index]
+	}
+	child, err := set(current, p, i+1, value)
+	if err != nil {
+		return nil, err
+	}
+	if step.Index == len(arr) {
+		return append(arr, child), nil
+	}
+	arr[step.Index] = child
+	return arr, nil
+}
@@ -0,0 +1,63 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"overview", "coreMechanics[3]", "mechanics.systems[0].subSystems[2]", "grid[1][2]"} {
+		p, err := Parse(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if p.String() != s {
+			t.Errorf("expected %s, got %s", s, p)
+		}
+	}
+
+	for _, s := range []string{"", "[0]", "a..b", "a[x]", "a[-1]", "a[1", "a[1]b"} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%q: expected ErrInvalidPath, got %v", s, err)
+		}
+	}
+}
+
+func TestGetAndSet(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"overview": "Old", "coreMechanics": ["Jump", "Dash"]}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := Parse("coreMechanics[1]")
+	if v, err := Get(doc, p); err != nil || v != "Dash" {
+		t.Errorf("expected Dash, got %v %v", v, err)
+	}
+
+	if _, err := Set(doc, p, "Glide"); err != nil {
+		t.Fatal(err)
+	}
+	appended, _ := Parse("coreMechanics[2]")
+	if _, err := Set(doc, appended, "Climb"); err != nil {
+		t.Fatal(err)
+	}
+	added, _ := Parse("extra.notes")
+	doc, err := Set(doc, added, "New")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, _ := json.Marshal(doc)
+	if string(out) != `{"coreMechanics":["Jump","Glide","Climb"],"extra":{"notes":"New"},"overview":"Old"}` {
+		t.Errorf("unexpected document %s", out)
+	}
+
+	for _, s := range []string{"coreMechanics[5]", "overview[0]", "coreMechanics.name"} {
+		p, _ := Parse(s)
+		if _, err := Set(doc, p, "x"); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%s: expected ErrInvalidPath, got %v", s, err)
+		}
+	}
+}
@@ -1,74 +1,132 @@
 package limiter
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
-
-	"golang.org/x/time/rate"
 )
 
-type Limiter struct {
-	Clients map[string]*Client
-	window  rate.Limit
-	limit   int
+var ErrUnknownPolicy = errors.New("unknown rate limit policy")
+
+// Policy gives every client Limit tokens that refill evenly over Window.
+// Requests spend tokens by cost, so an expensive route can use up a policy
+// faster than a cheap one.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// ParsePolicies parses comma separated policies such as
+// "rating=50/24h,designdoc=60/24h".
+func ParsePolicies(spec string) ([]Policy, error) {
+	var policies []Policy
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		limit, window, ok2 := strings.Cut(value, "/")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("rate limit policy %q must look like name=limit/window", entry)
+		}
+		p := Policy{Name: strings.TrimSpace(name)}
+		var err error
+		if p.Limit, err = strconv.Atoi(limit); err != nil || p.Limit < 1 {
+			return nil, fmt.Errorf("rate limit policy %s: limit must be a positive number", p.Name)
+		}
+		if p.Window, err = time.ParseDuration(window); err != nil || p.Window <= 0 {
+			return nil, fmt.Errorf("rate limit policy %s: window must be a positive duration such as 24h", p.Name)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
 }
 
-type Client struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
+// Result is the state of a client's bucket after a request, with what the
+// rate limit headers need.
+type Result struct {
+	Allowed   bool
+	Cost      int
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the request could be allowed, zero when
+	// it was allowed or can never be because it costs more than Limit
+	RetryAfter time.Duration
 }
 
-func NewLimiter() *Limiter {
-	limit := 10.0
-	duration := 24 * time.Hour
-	window := limit / duration.Seconds()
+// Limiter spends the tokens of client buckets kept in a Store. A bucket
+// untouched for a whole window is full again, so stores may drop it then.
+type Limiter struct {
+	store    Store
+	policies map[string]Policy
+	now      func() time.Time
+}
 
+func NewLimiter(store Store, policies []Policy) *Limiter {
 	l := &Limiter{
-		Clients: make(map[string]*Client),
-		limit:   10,
-		window:  rate.Limit(window),
+		store:    store,
+		policies: make(map[string]Policy),
+		now:      time.Now,
+	}
+	for _, p := range policies {
+		l.policies[p.Name] = p
 	}
 	return l
 }
 
-func (l *Limiter) AddClient(key string) {
-	_, exists := l.Clients[key]
-	if exists {
-		log.Printf("Client %s already exists. Updating last seen time\n", key)
-		l.UpdateClient(key)
-		return
-	}
+// RemoveExpired drops the buckets that refilled since they were last used.
+func (l *Limiter) RemoveExpired() error {
+	return l.store.RemoveExpired(l.now())
+}
 
-	c := &Client{
-		limiter:  rate.NewLimiter(l.window, l.limit),
-		lastSeen: time.Now(),
+// Take spends cost tokens of the client's bucket for the policy. A request
+// is allowed only when the bucket holds its whole cost, nothing is spent
+// otherwise. A cost of zero only reports the bucket.
+func (l *Limiter) Take(policy string, key string, cost int) (Result, error) {
+	p, ok := l.policies[policy]
+	if !ok {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnknownPolicy, policy)
 	}
 
-	l.Clients[key] = c
-	log.Printf("Client %s added to limiter\n", key)
-}
+	now := l.now()
+	limit := float64(p.Limit)
+	perSecond := limit / p.Window.Seconds()
+	res := Result{Cost: cost, Limit: p.Limit}
 
-func (l *Limiter) RemoveClients() {
-	log.Println("Removing clients")
-	for k, v := range l.Clients {
-		dur := time.Since(v.lastSeen)
-		if dur > 30*time.Second {
-			delete(l.Clients, k)
+	// every policy has its own bucket per client
+	err := l.store.Update(p.Name+"_"+key, p.Window, func(b *Bucket) {
+		tokens := limit
+		if !b.Updated.IsZero() {
+			elapsed := max(now.Sub(b.Updated).Seconds(), 0)
+			tokens = min(b.Tokens+elapsed*perSecond, limit)
 		}
-	}
-}
 
-func (l *Limiter) UpdateClient(key string) {
-	c, ok := l.Clients[key]
-	if !ok {
-		return
+		res.Allowed = float64(cost) <= tokens
+		if res.Allowed {
+			tokens -= float64(cost)
+		} else if cost <= p.Limit {
+			res.RetryAfter = seconds((float64(cost) - tokens) / perSecond)
+		}
+		res.Remaining = int(math.Floor(tokens))
+		res.Reset = seconds((limit - tokens) / perSecond)
+
+		b.Tokens = tokens
+		b.Updated = now
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("updating rate limit bucket: %w", err)
 	}
-	c.lastSeen = time.Now()
+	return res, nil
 }
 
-func (l *Limiter) ClientAllowed(key string) bool {
-	l.AddClient(key)
-
-	allowed := l.Clients[key].limiter.Allow()
-	return allowed
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
 }
@@ -0,0 +1,128 @@
+package limiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gdrsapi/pkg/store"
+)
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies("rating=50/24h, designdoc=60/12h,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Policy{
+		{Name: "rating", Limit: 50, Window: 24 * time.Hour},
+		{Name: "designdoc", Limit: 60, Window: 12 * time.Hour},
+	}
+	if len(policies) != len(expected) || policies[0] != expected[0] || policies[1] != expected[1] {
+		t.Errorf("unexpected policies %+v", policies)
+	}
+
+	for _, spec := range []string{"rating", "rating=50", "rating=0/24h", "rating=50/day", "=50/24h"} {
+		if _, err := ParsePolicies(spec); err == nil {
+			t.Errorf("expected ParsePolicies(%q) to fail", spec)
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(NewMemoryStore(), []Policy{
+		{Name: "rating", Limit: 10, Window: 10 * time.Hour},
+		{Name: "default", Limit: 100, Window: time.Hour},
+	})
+	l.now = func() time.Time { return now }
+
+	res, err := l.Take("rating", "10.0.0.1", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Limit != 10 || res.Remaining != 5 || res.Reset != 5*time.Hour {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	// a request costing more than what is left spends nothing
+	res, _ = l.Take("rating", "10.0.0.1", 8)
+	if res.Allowed || res.Remaining != 5 || res.RetryAfter != 3*time.Hour {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	// policies and clients have their own buckets
+	if res, _ := l.Take("default", "10.0.0.1", 1); !res.Allowed || res.Remaining != 99 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if res, _ := l.Take("rating", "10.0.0.2", 10); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	// tokens refill over the window
+	now = now.Add(3 * time.Hour)
+	if res, _ := l.Take("rating", "10.0.0.1", 8); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	if res, _ := l.Take("rating", "10.0.0.3", 11); res.Allowed || res.RetryAfter != 0 {
+		t.Errorf("a cost above the limit can never be allowed, got %+v", res)
+	}
+	if _, err := l.Take("chat", "10.0.0.1", 1); !errors.Is(err, ErrUnknownPolicy) {
+		t.Errorf("expected ErrUnknownPolicy, got %v", err)
+	}
+}
+
+func TestStores(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]func() Store{
+		"memory": func() Store { return NewMemoryStore() },
+		"file":   func() Store { return NewFileStore(s) },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+			policies := []Policy{{Name: "rating", Limit: 10, Window: 24 * time.Hour}}
+			l := NewLimiter(newStore(), policies)
+			l.now = func() time.Time { return now }
+
+			if res, err := l.Take("rating", "2001:db8::1", 10); err != nil || !res.Allowed {
+				t.Fatalf("unexpected result %+v %v", res, err)
+			}
+
+			// a quiet client is not evicted with a fresh bucket
+			now = now.Add(time.Minute)
+			if err := l.RemoveExpired(); err != nil {
+				t.Fatal(err)
+			}
+			if res, _ := l.Take("rating", "2001:db8::1", 1); res.Allowed {
+				t.Errorf("expected the bucket to be kept, got %+v", res)
+			}
+
+			// once a whole window went by the bucket is full and dropped
+			now = now.Add(25 * time.Hour)
+			if err := l.RemoveExpired(); err != nil {
+				t.Fatal(err)
+			}
+			if res, _ := l.Take("rating", "2001:db8::1", 10); !res.Allowed {
+				t.Errorf("expected a full bucket, got %+v", res)
+			}
+		})
+	}
+
+	// file buckets survive a restart
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	policies := []Policy{{Name: "designdoc", Limit: 3, Window: time.Hour}}
+	l := NewLimiter(NewFileStore(s), policies)
+	l.now = func() time.Time { return now }
+	l.Take("designdoc", "10.0.0.1", 3)
+
+	restarted := NewLimiter(NewFileStore(s), policies)
+	restarted.now = func() time.Time { return now }
+	if res, _ := restarted.Take("designdoc", "10.0.0.1", 1); res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected the bucket to survive a restart, got %+v", res)
+	}
+}
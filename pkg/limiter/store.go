@@ -0,0 +1,122 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"gdrsapi/pkg/store"
+)
+
+const bucketsCollection = "ratelimits"
+
+// Bucket is what is left of a client's tokens for a policy.
+type Bucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// Store keeps the bucket of every client and policy, so limits hold when a
+// client goes quiet and, for stores that persist, across restarts. It only
+// asks for what a shared cache such as Redis offers: an atomic read and
+// write of one key, and keys that expire.
+type Store interface {
+	// Update passes the bucket under key to fn, a zero bucket when there is
+	// none, and saves it until ttl after its Updated time. Updates of the
+	// same key must not interleave.
+	Update(key string, ttl time.Duration, fn func(b *Bucket)) error
+	// RemoveExpired drops the buckets kept past their ttl. Stores whose keys
+	// expire on their own have nothing to do.
+	RemoveExpired(now time.Time) error
+}
+
+type storedBucket struct {
+	Bucket
+	Expires time.Time `json:"expires"`
+}
+
+// MemoryStore keeps buckets in memory, they are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]storedBucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]storedBucket)}
+}
+
+func (m *MemoryStore) Update(key string, ttl time.Duration, fn func(b *Bucket)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.buckets[key]
+	fn(&b.Bucket)
+	b.Expires = b.Updated.Add(ttl)
+	m.buckets[key] = b
+	return nil
+}
+
+func (m *MemoryStore) RemoveExpired(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, b := range m.buckets {
+		if now.After(b.Expires) {
+			delete(m.buckets, k)
+		}
+	}
+	return nil
+}
+
+// FileStore keeps buckets as records of the store, so limits survive
+// restarts. Updates are only atomic within one process.
+type FileStore struct {
+	mu    sync.Mutex
+	store *store.Store
+}
+
+func NewFileStore(store *store.Store) *FileStore {
+	return &FileStore{store: store}
+}
+
+func (f *FileStore) Update(key string, ttl time.Duration, fn func(b *Bucket)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// client keys are ips, escaping keeps anything else a valid file name
+	key = url.PathEscape(key)
+	var b storedBucket
+	if err := f.store.Get(bucketsCollection, key, &b); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	fn(&b.Bucket)
+	b.Expires = b.Updated.Add(ttl)
+	if err := f.store.Put(bucketsCollection, key, b); err != nil {
+		return fmt.Errorf("saving rate limit bucket: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStore) RemoveExpired(now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.store.Keys(bucketsCollection)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		var b storedBucket
+		if err := f.store.Get(bucketsCollection, key, &b); err != nil {
+			return err
+		}
+		if now.After(b.Expires) {
+			if err := f.store.Delete(bucketsCollection, key); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,21 +1,162 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"regexp"
+	"strings"
 )
 
+// AppLogger writes structured logs through slog. Use the context methods,
+// such as InfoContext, so lines carry the id of the request they belong to.
 type AppLogger struct {
-	InfoLog  *log.Logger
-	ErrorLog *log.Logger
+	*slog.Logger
 }
 
+// Options configures a logger. Format is json or text, Level is debug, info,
+// warn or error. Secrets are replaced wherever they show up in a line.
+type Options struct {
+	Level   string
+	Format  string
+	Secrets []string
+	Output  io.Writer
+}
+
+// NewAppLogger returns a json logger at info level.
 func NewAppLogger() *AppLogger {
-	infoLog := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	errorLog := log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+	return New(Options{})
+}
+
+func New(opts Options) *AppLogger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+
+	r := newRedactor(opts.Secrets, level <= slog.LevelDebug)
+	handlerOpts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: r.replaceAttr,
+	}
+	var h slog.Handler
+	if strings.EqualFold(opts.Format, "text") {
+		h = slog.NewTextHandler(opts.Output, handlerOpts)
+	} else {
+		h = slog.NewJSONHandler(opts.Output, handlerOpts)
+	}
+	h = contextHandler{h}
 
 	return &AppLogger{
-		InfoLog:  infoLog,
-		ErrorLog: errorLog,
+		Logger: slog.New(h),
+	}
+}
+
+type ctxKey struct{}
+
+// WithRequestID returns a context whose log lines carry the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestID returns the request id carried by ctx.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generating request id: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// contextHandler adds the request id of the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("requestId", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// sensitive is a value only shown at debug level, such as a prompt or a
+// model response.
+type sensitive string
+
+// Sensitive returns an attribute whose value is only logged at debug level.
+// Other levels log its length.
+func Sensitive(key string, value string) slog.Attr {
+	return slog.Any(key, sensitive(value))
+}
+
+var (
+	// api keys in urls, bearer tokens and our own api keys
+	keyParam    = regexp.MustCompile(`([?&](?:key|api_key|token)=)[^&\s"]+`)
+	bearerToken = regexp.MustCompile(`(?i)(bearer\s+)[^\s"]+`)
+	apiKey      = regexp.MustCompile(`gdrs_[0-9a-f]+_[0-9a-f]+`)
+)
+
+type redactor struct {
+	secrets []string
+	debug   bool
+}
+
+func newRedactor(secrets []string, debug bool) *redactor {
+	r := &redactor{debug: debug}
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	return r
+}
+
+// redact removes anything that looks like a credential from s.
+func (r *redactor) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "[redacted]")
+	}
+	s = keyParam.ReplaceAllString(s, "${1}[redacted]")
+	s = bearerToken.ReplaceAllString(s, "${1}[redacted]")
+	return apiKey.ReplaceAllString(s, "gdrs_[redacted]")
+}
+
+func (r *redactor) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case sensitive:
+			if !r.debug {
+				return slog.String(a.Key, fmt.Sprintf("[%d bytes, shown at debug level]", len(v)))
+			}
+			a.Value = slog.StringValue(r.redact(string(v)))
+		case error:
+			a.Value = slog.StringValue(r.redact(v.Error()))
+		}
 	}
+	return a
 }
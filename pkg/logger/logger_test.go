@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid json line %q: %s", line, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Output: &buf})
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	l.InfoContext(ctx, "rating app", "appId", "620")
+	l.Info("no request")
+	l.DebugContext(ctx, "hidden at info level")
+	l.ErrorContext(WithRequestID(ctx, "req-2"), "request failed", "err", errors.New("timeout"))
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %s", len(lines), buf.String())
+	}
+	if lines[0]["requestId"] != "req-1" || lines[0]["appId"] != "620" || lines[0]["level"] != "INFO" {
+		t.Errorf("unexpected line %v", lines[0])
+	}
+	if _, ok := lines[1]["requestId"]; ok {
+		t.Errorf("expected no request id, got %v", lines[1])
+	}
+	// the latest id wins
+	if lines[2]["level"] != "ERROR" || lines[2]["requestId"] != "req-2" || lines[2]["err"] != "timeout" {
+		t.Errorf("unexpected line %v", lines[2])
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Output: &buf, Secrets: []string{"cf-secret"}})
+
+	l.Error("calling api",
+		"err", errors.New(`Post "https://example.com/v1?key=AIzaSy123&alt=json": timeout`),
+		"header", "Bearer cf-secret",
+		"token", "gdrs_0123abcd_deadbeef",
+		Sensitive("prompt", "Rate this steam page"),
+	)
+
+	line := decodeLines(t, &buf)[0]
+	if line["err"] != `Post "https://example.com/v1?key=[redacted]&alt=json": timeout` {
+		t.Errorf("unexpected err %v", line["err"])
+	}
+	if line["header"] != "Bearer [redacted]" || line["token"] != "gdrs_[redacted]" {
+		t.Errorf("expected credentials to be redacted, got %v", line)
+	}
+	if line["prompt"] != "[20 bytes, shown at debug level]" {
+		t.Errorf("expected the prompt to be hidden, got %v", line["prompt"])
+	}
+
+	buf.Reset()
+	debug := New(Options{Output: &buf, Level: "debug", Secrets: []string{"cf-secret"}})
+	debug.Debug("prompt", Sensitive("prompt", "Rate this steam page with key cf-secret"))
+	if line := decodeLines(t, &buf)[0]; line["prompt"] != "Rate this steam page with key [redacted]" {
+		t.Errorf("expected the prompt at debug level, got %v", line["prompt"])
+	}
+}
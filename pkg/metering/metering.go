@@ -0,0 +1,39 @@
+package metering
+
+import "context"
+
+const (
+	ProviderGemini     = "gemini"
+	ProviderCloudflare = "cloudflare"
+)
+
+// Call is the usage reported by one upstream api call.
+type Call struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	// Neurons is Cloudflare's billing unit, zero when the response does not
+	// report it
+	Neurons float64
+}
+
+// Recorder keeps the calls made with a context.
+type Recorder interface {
+	Record(ctx context.Context, call Call)
+}
+
+type ctxKey struct{}
+
+// WithRecorder returns a context whose calls are recorded by r.
+func WithRecorder(ctx context.Context, r Recorder) context.Context {
+	return context.WithValue(ctx, ctxKey{}, r)
+}
+
+// Record passes a call to the recorder carried by ctx. It does nothing for
+// contexts without one, so clients can always call it.
+func Record(ctx context.Context, call Call) {
+	if r, ok := ctx.Value(ctxKey{}).(Recorder); ok {
+		r.Record(ctx, call)
+	}
+}
@@ -0,0 +1,26 @@
+package metering
+
+import (
+	"context"
+	"testing"
+)
+
+type recorder []Call
+
+func (r *recorder) Record(ctx context.Context, call Call) {
+	*r = append(*r, call)
+}
+
+func TestRecord(t *testing.T) {
+	// without a recorder calls are dropped
+	Record(context.Background(), Call{Provider: ProviderGemini})
+
+	r := &recorder{}
+	ctx := WithRecorder(context.Background(), r)
+	Record(ctx, Call{Provider: ProviderGemini, InputTokens: 10})
+	Record(ctx, Call{Provider: ProviderCloudflare})
+
+	if len(*r) != 2 || (*r)[0].InputTokens != 10 || (*r)[1].Provider != ProviderCloudflare {
+		t.Errorf("unexpected calls %+v", *r)
+	}
+}
@@ -0,0 +1,257 @@
+// Package pdf writes simple text documents as PDF using the standard
+// Helvetica fonts, so nothing has to be embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	PageWidth  = 595.0 // A4 in points
+	PageHeight = 842.0
+	Margin     = 56.0
+
+	lineSpacing = 1.3
+)
+
+// Document lays out text top to bottom and starts a new page when the
+// current one is full.
+type Document struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+	bold  bool
+	size  float64
+	color [3]float64
+}
+
+func New() *Document {
+	d := &Document{size: 11}
+	d.addPage()
+	return d
+}
+
+func (d *Document) addPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+	d.y = PageHeight - Margin
+}
+
+func (d *Document) SetFont(bold bool, size float64) {
+	d.bold = bold
+	d.size = size
+}
+
+// SetColor sets the text and line color, each channel going from 0 to 1.
+func (d *Document) SetColor(r float64, g float64, b float64) {
+	d.color = [3]float64{r, g, b}
+}
+
+// Space moves the cursor down by h points.
+func (d *Document) Space(h float64) {
+	d.y -= h
+	if d.y < Margin {
+		d.addPage()
+	}
+}
+
+// Paragraph writes text wrapped to the page width. Newlines start new lines.
+func (d *Document) Paragraph(text string) {
+	d.ParagraphIndent(text, 0)
+}
+
+// ParagraphIndent writes a wrapped paragraph indented by indent points.
+func (d *Document) ParagraphIndent(text string, indent float64) {
+	lineHeight := d.size * lineSpacing
+	maxWidth := PageWidth - 2*Margin - indent
+
+	for _, line := range Wrap(text, d.bold, d.size, maxWidth) {
+		if d.y-lineHeight < Margin {
+			d.addPage()
+		}
+		d.y -= lineHeight
+		d.writeLine(line, Margin+indent, d.y+(lineHeight-d.size)/2)
+	}
+}
+
+// Rule draws a horizontal line across the page.
+func (d *Document) Rule() {
+	d.Space(6)
+	fmt.Fprintf(d.page, "%.3f %.3f %.3f RG 0.5 w %.2f %.2f m %.2f %.2f l S\n",
+		d.color[0], d.color[1], d.color[2], Margin, d.y, PageWidth-Margin, d.y)
+	d.Space(6)
+}
+
+func (d *Document) writeLine(line string, x float64, y float64) {
+	font := "F1"
+	if d.bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page, "BT %.3f %.3f %.3f rg /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		d.color[0], d.color[1], d.color[2], font, d.size, x, y, escape(encode(line)))
+}
+
+// Bytes returns the finished PDF file.
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	writeObj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// objects 1 to 4 are fixed, pages start at 5 with their content right after
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+i*2))
+	}
+
+	writeObj("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		writeObj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, 6+i*2))
+		writeObj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// Wrap splits text into lines no wider than maxWidth points.
+func Wrap(text string, bold bool, size float64, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+
+		line := ""
+		for _, word := range words {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+
+			if line != "" && TextWidth(candidate, bold, size) > maxWidth {
+				lines = append(lines, line)
+				candidate = word
+			}
+
+			// words longer than a line are cut wherever they overflow
+			for TextWidth(candidate, bold, size) > maxWidth {
+				cut := fitRunes(candidate, bold, size, maxWidth)
+				lines = append(lines, candidate[:cut])
+				candidate = candidate[cut:]
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func fitRunes(s string, bold bool, size float64, maxWidth float64) int {
+	end := 0
+	for i, r := range s {
+		if i > 0 && TextWidth(s[:i+len(string(r))], bold, size) > maxWidth {
+			break
+		}
+		end = i + len(string(r))
+	}
+	return end
+}
+
+// TextWidth returns the width of s in points.
+func TextWidth(s string, bold bool, size float64) float64 {
+	widths := helveticaWidths
+	if bold {
+		widths = helveticaBoldWidths
+	}
+
+	total := 0
+	for _, c := range encode(s) {
+		if c >= 32 && c <= 126 {
+			total += widths[c-32]
+		} else {
+			total += defaultWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// winAnsi maps the punctuation llm output tends to use to WinAnsiEncoding.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encode converts s to WinAnsiEncoding, replacing what it can not represent.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch b, ok := winAnsi[r]; {
+		case ok:
+			out = append(out, b)
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\r', '\t':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+const defaultWidth = 556
+
+// Helvetica and Helvetica-Bold advance widths for characters 32 to 126, in
+// thousandths of the font size, from the standard Adobe font metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
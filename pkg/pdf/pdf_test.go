@@ -0,0 +1,54 @@
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTextWidth(t *testing.T) {
+	// "Hi" is H (722) and i (222) in regular and H (722) and i (278) in bold
+	if w := TextWidth("Hi", false, 10); w != 9.44 {
+		t.Errorf("unexpected regular width %v", w)
+	}
+	if w := TextWidth("Hi", true, 10); w != 10 {
+		t.Errorf("unexpected bold width %v", w)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	text := strings.Repeat("steam page ", 40) + "\n" + strings.Repeat("x", 200)
+	lines := Wrap(text, false, 11, 200)
+
+	for _, line := range lines {
+		if TextWidth(line, false, 11) > 200 {
+			t.Errorf("line %q is wider than the limit", line)
+		}
+	}
+
+	joined := strings.Join(lines, "")
+	if strings.Count(joined, "x") != 200 {
+		t.Error("long words should be cut without losing characters")
+	}
+}
+
+func TestDocumentBytes(t *testing.T) {
+	d := New()
+	d.SetFont(true, 18)
+	d.Paragraph("Report (draft) \\ “quoted”")
+	d.SetFont(false, 11)
+	for i := 0; i < 120; i++ {
+		d.Paragraph("A line long enough to fill a few pages of the document.")
+	}
+
+	out := d.Bytes()
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("output is not framed as a pdf file")
+	}
+	if len(d.pages) < 2 {
+		t.Errorf("expected the text to flow onto a second page, got %d pages", len(d.pages))
+	}
+	if !bytes.Contains(out, []byte(`(Report \(draft\) \\ `+"\x93quoted\x94"+`) Tj`)) {
+		t.Error("expected text to be escaped and encoded as WinAnsi")
+	}
+}
@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// For generates a schema from a Go value, usually the struct an LLM response
+// is decoded into. Field names come from the json tag, fields tagged with
+// omitempty or held by pointer are optional and everything else is required.
+//
+// Constraints can be added with a schema tag and a description tag:
+//
+//	Status string `json:"status" schema:"enum=pass|fail" description:"verdict"`
+//	Score  int    `json:"score" schema:"min=0,max=100"`
+//	Tags   []string `json:"tags" schema:"minItems=1,maxItems=20"`
+func For(v interface{}) *Schema {
+	return FromType(reflect.TypeOf(v))
+}
+
+// FromType generates a schema from a Go type, see For.
+func FromType(t reflect.Type) *Schema {
+	if t == nil {
+		return Object()
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return Number()
+	case reflect.Slice, reflect.Array:
+		// encoding/json writes byte slices as base64 strings
+		if t.Elem().Kind() == reflect.Uint8 {
+			return String()
+		}
+		return Array(FromType(t.Elem()))
+	case reflect.Struct:
+		s := Object()
+		addStructFields(s, t)
+		return s
+	}
+
+	// maps and interfaces have no fixed shape
+	return Object()
+}
+
+func addStructFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				addStructFields(s, fieldType)
+				continue
+			}
+		}
+
+		if fieldType.Kind() == reflect.Interface {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		p := FromType(fieldType)
+		p.Description = field.Tag.Get("description")
+		applyTag(p, field.Tag.Get("schema"))
+
+		optional := strings.Contains(opts, "omitempty") || fieldType.Kind() == reflect.Pointer
+		if fieldType.Kind() == reflect.Pointer {
+			p.Nullable = true
+		}
+
+		if optional {
+			s.OptionalProperty(name, p)
+		} else {
+			s.Property(name, p)
+		}
+	}
+}
+
+func applyTag(s *Schema, tag string) {
+	if tag == "" {
+		return
+	}
+
+	for _, opt := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "enum":
+			s.Enum = strings.Split(value, "|")
+		case "min":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				s.Minimum = &f
+			}
+		case "max":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				s.Maximum = &f
+			}
+		case "minItems":
+			if n, err := strconv.Atoi(value); err == nil {
+				s.MinItems = &n
+			}
+		case "maxItems":
+			if n, err := strconv.Atoi(value); err == nil {
+				s.MaxItems = &n
+			}
+		}
+	}
+}
+
+// AllOptional returns a shallow copy of the schema where no top level
+// property is required, for replies that only contain some of the fields.
+func (s *Schema) AllOptional() *Schema {
+	c := *s
+	c.Required = nil
+	return &c
+}
@@ -0,0 +1,92 @@
+package schema
+
+// Types follow the OpenAPI subset understood by the Gemini API.
+const (
+	TypeString  = "STRING"
+	TypeNumber  = "NUMBER"
+	TypeInteger = "INTEGER"
+	TypeBoolean = "BOOLEAN"
+	TypeArray   = "ARRAY"
+	TypeObject  = "OBJECT"
+)
+
+// Schema describes the expected shape of a JSON value. It serializes to the
+// format Gemini expects for responseSchema and can validate raw JSON.
+type Schema struct {
+	Type             string             `json:"type"`
+	Description      string             `json:"description,omitempty"`
+	Nullable         bool               `json:"nullable,omitempty"`
+	Enum             []string           `json:"enum,omitempty"`
+	Properties       map[string]*Schema `json:"properties,omitempty"`
+	Required         []string           `json:"required,omitempty"`
+	PropertyOrdering []string           `json:"propertyOrdering,omitempty"`
+	Items            *Schema            `json:"items,omitempty"`
+	MinItems         *int               `json:"minItems,omitempty"`
+	MaxItems         *int               `json:"maxItems,omitempty"`
+	Minimum          *float64           `json:"minimum,omitempty"`
+	Maximum          *float64           `json:"maximum,omitempty"`
+}
+
+func String() *Schema {
+	return &Schema{Type: TypeString}
+}
+
+func Number() *Schema {
+	return &Schema{Type: TypeNumber}
+}
+
+func Integer() *Schema {
+	return &Schema{Type: TypeInteger}
+}
+
+func Boolean() *Schema {
+	return &Schema{Type: TypeBoolean}
+}
+
+func Enum(values ...string) *Schema {
+	return &Schema{Type: TypeString, Enum: values}
+}
+
+func Array(items *Schema) *Schema {
+	return &Schema{Type: TypeArray, Items: items}
+}
+
+func Object() *Schema {
+	return &Schema{Type: TypeObject, Properties: map[string]*Schema{}}
+}
+
+// Property adds a required property, keeping the order properties were added in.
+func (s *Schema) Property(name string, p *Schema) *Schema {
+	s.OptionalProperty(name, p)
+	s.Required = append(s.Required, name)
+	return s
+}
+
+// OptionalProperty adds a property that may be missing from the value.
+func (s *Schema) OptionalProperty(name string, p *Schema) *Schema {
+	if s.Properties == nil {
+		s.Properties = map[string]*Schema{}
+	}
+	s.Properties[name] = p
+	s.PropertyOrdering = append(s.PropertyOrdering, name)
+	return s
+}
+
+func (s *Schema) Describe(description string) *Schema {
+	s.Description = description
+	return s
+}
+
+// WithRange sets the inclusive bounds of a number or integer.
+func (s *Schema) WithRange(min, max float64) *Schema {
+	s.Minimum = &min
+	s.Maximum = &max
+	return s
+}
+
+// WithItems sets the inclusive bounds on the length of an array.
+func (s *Schema) WithItems(min, max int) *Schema {
+	s.MinItems = &min
+	s.MaxItems = &max
+	return s
+}
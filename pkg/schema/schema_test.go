@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s := Object().
+		Property("score", Integer().WithRange(0, 100)).
+		Property("status", Enum("pass", "fail")).
+		Property("tags", Array(String()).WithItems(1, 3)).
+		OptionalProperty("note", String())
+
+	valid := `{"score": 80, "status": "pass", "tags": ["a", "b"]}`
+	if err := s.Validate([]byte(valid)); err != nil {
+		t.Errorf("expected valid json, got %v", err)
+	}
+
+	invalid := `{"score": 120.5, "status": "maybe", "tags": [], "note": 3}`
+	err := s.Validate([]byte(invalid))
+
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	// integer, maximum, enum, minItems and note type
+	if len(vErr.Problems) != 5 {
+		t.Errorf("expected 5 problems, got %d: %v", len(vErr.Problems), vErr.Problems)
+	}
+
+	if err := s.Validate([]byte(`{"score": 10}`)); err == nil {
+		t.Errorf("expected missing properties to fail validation")
+	}
+
+	if err := s.Validate([]byte(`not json`)); err == nil {
+		t.Errorf("expected invalid json to fail validation")
+	}
+}
+
+func TestFor(t *testing.T) {
+	type item struct {
+		ID     string `json:"id"`
+		Status string `json:"status" schema:"enum=pass|fail" description:"item verdict"`
+	}
+
+	type doc struct {
+		Title    string   `json:"title"`
+		Score    int      `json:"score" schema:"min=0,max=100"`
+		Tags     []string `json:"tags,omitempty" schema:"maxItems=2"`
+		Items    []item   `json:"items"`
+		Parent   *item    `json:"parent"`
+		internal string
+	}
+
+	s := For(doc{})
+	if s.Type != TypeObject || len(s.Properties) != 5 {
+		t.Fatalf("expected an object with 5 properties, got %+v", s)
+	}
+
+	if got := strings.Join(s.Required, ","); got != "title,score,items" {
+		t.Errorf("unexpected required properties %s", got)
+	}
+
+	status := s.Properties["items"].Items.Properties["status"]
+	if status.Description != "item verdict" || len(status.Enum) != 2 {
+		t.Errorf("expected tags to be applied, got %+v", status)
+	}
+
+	if !s.Properties["parent"].Nullable {
+		t.Errorf("expected pointer fields to be nullable")
+	}
+
+	if err := s.Validate([]byte(`{"title": "a", "score": 101, "items": [], "tags": ["a", "b", "c"]}`)); err == nil {
+		t.Errorf("expected generated constraints to be validated")
+	}
+}
@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ValidationError lists every problem found while validating a value.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "schema validation failed: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks that data is valid JSON matching the schema.
+func (s *Schema) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+
+	return s.ValidateValue(value)
+}
+
+// ValidateValue checks a decoded JSON value. Numbers may be float64 or json.Number.
+func (s *Schema) ValidateValue(value interface{}) error {
+	var problems []string
+	s.validate("$", value, &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, value interface{}, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if value == nil {
+		if !s.Nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case TypeString:
+		str, ok := value.(string)
+		if !ok {
+			fail("expected a string")
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			fail("%q is not one of %s", str, strings.Join(s.Enum, ", "))
+		}
+
+	case TypeNumber, TypeInteger:
+		n, ok := toFloat(value)
+		if !ok {
+			fail("expected a number")
+			return
+		}
+		if s.Type == TypeInteger && n != float64(int64(n)) {
+			fail("expected an integer")
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			fail("%v is less than the minimum %v", n, *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fail("%v is greater than the maximum %v", n, *s.Maximum)
+		}
+
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			fail("expected a boolean")
+		}
+
+	case TypeArray:
+		arr, ok := value.([]interface{})
+		if !ok {
+			fail("expected an array")
+			return
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			fail("expected at least %d items, got %d", *s.MinItems, len(arr))
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			fail("expected at most %d items, got %d", *s.MaxItems, len(arr))
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+
+	case TypeObject:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for name, prop := range s.Properties {
+			if v, ok := obj[name]; ok {
+				prop.validate(path+"."+name, v, problems)
+			}
+		}
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
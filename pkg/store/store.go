@@ -0,0 +1,159 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+// Store persists json records on disk, one file per record under
+// dir/collection/key.json. Writes go through a temp file and a rename so a
+// crash never leaves a half written record behind.
+type Store struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating store dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(collection string, key string) (string, error) {
+	if err := validName(collection); err != nil {
+		return "", fmt.Errorf("invalid collection: %w", err)
+	}
+	if err := validName(key); err != nil {
+		return "", fmt.Errorf("invalid key: %w", err)
+	}
+	return filepath.Join(s.dir, collection, key+".json"), nil
+}
+
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%q is not a valid name", name)
+	}
+	return nil
+}
+
+// Put writes v as the record for key, replacing any previous record.
+func (s *Store) Put(collection string, key string, v interface{}) error {
+	path, err := s.path(collection, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating collection dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing record: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing record: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing record: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("saving record: %w", err)
+	}
+	return nil
+}
+
+// Get decodes the record for key into v. It returns ErrNotFound when the
+// record does not exist.
+func (s *Store) Get(collection string, key string, v interface{}) error {
+	path, err := s.path(collection, key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	data, err := os.ReadFile(path)
+	s.mu.RUnlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decoding record %s/%s: %w", collection, key, err)
+	}
+	return nil
+}
+
+// Delete removes the record for key. It returns ErrNotFound when the record
+// does not exist.
+func (s *Store) Delete(collection string, key string) error {
+	path, err := s.path(collection, key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Keys returns the sorted keys of every record in the collection.
+func (s *Store) Keys(collection string) ([]string, error) {
+	if err := validName(collection); err != nil {
+		return nil, fmt.Errorf("invalid collection: %w", err)
+	}
+
+	s.mu.RLock()
+	entries, err := os.ReadDir(filepath.Join(s.dir, collection))
+	s.mu.RUnlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listing collection: %w", err)
+	}
+
+	var keys []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		keys = append(keys, strings.TrimSuffix(name, ".json"))
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
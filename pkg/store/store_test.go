@@ -0,0 +1,53 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type record struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+func TestStore(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("games", "620", record{Name: "Portal 2", Score: 80}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("games", "400", record{Name: "Portal", Score: 70}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got record
+	if err := s.Get("games", "620", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != (record{Name: "Portal 2", Score: 80}) {
+		t.Errorf("unexpected record %+v", got)
+	}
+
+	keys, err := s.Keys("games")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(keys, []string{"400", "620"}) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if err := s.Delete("games", "620"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get("games", "620", &got); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+
+	if err := s.Put("games", "../escape", record{}); err == nil {
+		t.Error("expected an error for a key with a path separator")
+	}
+}